@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 服务版本号，发布时修改这里
+const version = "1.0.0"
+
+var (
+	// 服务启动时间，在main()中设置，用于计算运行时长
+	startTime time.Time
+	// 服务是否正在关闭，关闭期间健康检查返回503让负载均衡摘除流量
+	shuttingDown atomic.Bool
+	// 正在处理中的请求数，由inFlightMiddleware维护
+	inFlightRequests atomic.Int64
+)
+
+// 优雅关闭时检查进行中请求数的间隔
+const drainPollInterval = 50 * time.Millisecond
+
+// waitForDrain 等待进行中的请求全部完成，ctx到期时返回剩余的请求数
+func waitForDrain(ctx context.Context) int64 {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		n := inFlightRequests.Load()
+		if n == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return n
+		case <-ticker.C:
+		}
+	}
+}
+
+// 健康检查返回的JSON数据
+type HealthResponse struct {
+	Status        string `json:"status"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Version       string `json:"version"`
+	InFlight      int64  `json:"in_flight"` // 包含本次健康检查请求
+	Maintenance   bool   `json:"maintenance"`
+}
+
+// healthHandler 处理/api/health，供负载均衡做健康检查
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := HealthResponse{
+		Status:        "ok",
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+		Version:       version,
+		InFlight:      inFlightRequests.Load(),
+		Maintenance:   inMaintenance(),
+	}
+	if shuttingDown.Load() {
+		response.Status = "shutting down"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// API出错时返回的JSON数据
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeJSONError 以JSON格式写出错误信息
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
+
+// 上传成功时返回的JSON数据
+type UploadResponse struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// uploadHandler 处理POST /api/upload，把multipart表单中的file字段保存到dir()返回的静态目录下，
+// 目标路径已存在时返回409，不会覆盖已有文件。readonly为true时拒绝所有上传
+func uploadHandler(dir func() string, maxBytes int64, readonly bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if readonly {
+			writeJSONError(w, http.StatusForbidden, "服务端处于只读模式，不允许上传")
+			return
+		}
+
+		// 限制请求体大小，超出时读取会返回*http.MaxBytesError
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("上传文件超过大小限制 %d 字节", maxBytes))
+				return
+			}
+			writeJSONError(w, http.StatusBadRequest, "读取上传文件失败: "+err.Error())
+			return
+		}
+		defer file.Close()
+
+		name, err := cleanUploadName(header.Filename)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		size, err := saveUpload(filepath.Join(dir(), name), file)
+		if err != nil {
+			if errors.Is(err, errUploadExists) {
+				writeJSONError(w, http.StatusConflict, fmt.Sprintf("文件 %s 已存在，不允许覆盖", "/"+filepath.ToSlash(name)))
+				return
+			}
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("上传文件超过大小限制 %d 字节", maxBytes))
+				return
+			}
+			log.Printf("保存上传文件 %s 失败: %v", name, err)
+			writeJSONError(w, http.StatusInternalServerError, "保存上传文件失败")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(UploadResponse{Path: "/" + filepath.ToSlash(name), Size: size})
+	}
+}
+
+// cleanUploadName 校验上传文件名，只允许静态目录内的相对路径，防止路径穿越
+func cleanUploadName(filename string) (string, error) {
+	name := filepath.Clean(filepath.FromSlash(filename))
+	if filename == "" || name == "." || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("非法的文件名 %q", filename)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part == ".." {
+			return "", fmt.Errorf("非法的文件名 %q", filename)
+		}
+	}
+	return name, nil
+}
+
+// errUploadExists 上传的目标路径已存在时由saveUpload返回
+var errUploadExists = errors.New("文件已存在")
+
+// saveUpload 先写入同目录下的临时文件，再以硬链接的方式放到目标路径，避免客户端读到写了一半的文件。
+// 与os.Rename不同，os.Link在目标已存在时失败，因此不会覆盖index.html等已有文件
+func saveUpload(dst string, src io.Reader) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return 0, err
+	}
+	if err := os.Link(tmp.Name(), dst); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return 0, errUploadExists
+		}
+		return 0, err
+	}
+	return size, nil
+}
+
+// /api/echo返回的请求信息
+type EchoResponse struct {
+	Method        string              `json:"method"`
+	Path          string              `json:"path"`
+	Headers       map[string][]string `json:"headers"`
+	Query         map[string][]string `json:"query"`
+	Body          string              `json:"body"`
+	BodyTruncated bool                `json:"body_truncated"`
+	RequestID     string              `json:"request_id,omitempty"`
+}
+
+// echoHandler 处理/api/echo，把收到的请求原样以JSON返回，便于调试代理和客户端。
+// 请求体最多读取maxBody字节，超出部分丢弃并标记body_truncated
+func echoHandler(maxBody int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+		var maxErr *http.MaxBytesError
+		if err != nil && !errors.As(err, &maxErr) {
+			writeJSONError(w, http.StatusBadRequest, "读取请求体失败: "+err.Error())
+			return
+		}
+
+		response := EchoResponse{
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Headers:       r.Header,
+			Query:         r.URL.Query(),
+			Body:          string(body),
+			BodyTruncated: maxErr != nil,
+			RequestID:     requestIDFromContext(r.Context()),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// /api/json允许的最大请求体字节数
+const maxJSONBody = 1 << 20
+
+// jsonHandler 处理POST /api/json，校验请求中的JsonResponse并附带服务端时间返回
+func jsonHandler(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		writeJSONError(w, http.StatusUnsupportedMediaType, "Content-Type必须是application/json")
+		return
+	}
+
+	var request JsonResponse
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&request); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("请求体超过 %d 字节", maxJSONBody))
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "解析JSON失败: "+err.Error())
+		return
+	}
+	if dec.More() {
+		writeJSONError(w, http.StatusBadRequest, "请求体只能包含一个JSON对象")
+		return
+	}
+	if strings.TrimSpace(request.Message) == "" {
+		writeJSONError(w, http.StatusBadRequest, "message不能为空")
+		return
+	}
+
+	// time字段由服务端填写
+	request.Time = time.Now().Format(time.RFC3339)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(request)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanUploadName(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"a.txt", "a.txt", false},
+		{"sub/a.txt", filepath.FromSlash("sub/a.txt"), false},
+		{"sub/../a.txt", "a.txt", false},
+		{"./a.txt", "a.txt", false},
+		{"", "", true},
+		{".", "", true},
+		{"..", "", true},
+		{"../a.txt", "", true},
+		{"sub/../../a.txt", "", true},
+		{"/etc/passwd", "", true},
+		{"//etc/passwd", "", true},
+	}
+	for _, tt := range tests {
+		got, err := cleanUploadName(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("cleanUploadName(%q) = %q, %v, want %q, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestUploadHandler(t *testing.T) {
+	dir := t.TempDir()
+	upload := func(h http.Handler, filename, content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile("file", filename)
+		fw.Write([]byte(content))
+		mw.Close()
+		r := httptest.NewRequest(http.MethodPost, "/api/upload", &body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	dirFunc := func() string { return dir }
+
+	if w := upload(uploadHandler(dirFunc, 1<<20, false), "../escape.txt", "x"); w.Code != http.StatusOK {
+		t.Fatalf("upload status = %d: %s", w.Code, w.Body)
+	}
+	// 文件名中的路径部分被去掉，文件只能落在上传目录内
+	if _, err := os.Stat(filepath.Join(dir, "escape.txt")); err != nil {
+		t.Errorf("文件未保存在上传目录内: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt")); err == nil {
+		t.Errorf("文件被写到了上传目录之外")
+	}
+
+	// 已存在的文件不能被覆盖
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("home"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"index.html", "escape.txt"} {
+		if w := upload(uploadHandler(dirFunc, 1<<20, false), name, "overwritten"); w.Code != http.StatusConflict {
+			t.Errorf("覆盖 %s: status = %d, want 409", name, w.Code)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "index.html")); string(data) != "home" {
+		t.Errorf("index.html 被覆盖为 %q", data)
+	}
+
+	if w := upload(uploadHandler(dirFunc, 4, false), "big.txt", "too large"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("超过大小限制: status = %d, want 413", w.Code)
+	}
+	if w := upload(uploadHandler(dirFunc, 1<<20, true), "ro.txt", "x"); w.Code != http.StatusForbidden {
+		t.Errorf("只读模式: status = %d, want 403", w.Code)
+	}
+	// 临时文件在成功和失败时都应被清理
+	if matches, _ := filepath.Glob(filepath.Join(dir, ".upload-*")); len(matches) != 0 {
+		t.Errorf("残留临时文件: %v", matches)
+	}
+}
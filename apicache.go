@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// API响应缓存最多保存的条目数，超出时淘汰最久未使用的
+	apiCacheMaxEntries = 256
+	// 超过该大小的响应体不缓存，避免占用过多内存
+	apiCacheMaxBody = 1 << 20
+)
+
+// apiCacheEntry 是一条缓存的响应
+type apiCacheEntry struct {
+	key     string
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// apiCache 是带TTL的LRU响应缓存，可并发使用
+type apiCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newAPICache(ttl time.Duration, maxEntries int) *apiCache {
+	return &apiCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// get 返回未过期的缓存条目，并把它移到最近使用的位置
+func (c *apiCache) get(key string) (*apiCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*apiCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry, true
+}
+
+func (c *apiCache) set(entry *apiCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[entry.key]; ok {
+		el.Value = entry
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[entry.key] = c.ll.PushFront(entry)
+	for c.ll.Len() > c.maxEntries {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*apiCacheEntry).key)
+	}
+}
+
+// middleware 缓存GET请求的2xx响应，缓存键为完整的请求URL(含查询参数)。
+// 命中时直接写出缓存的状态码、响应头和响应体，并设置X-Cache: HIT
+func (c *apiCache) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := r.URL.RequestURI()
+		if entry, ok := c.get(key); ok {
+			h := w.Header()
+			// 外层中间件已设置的头(如X-Request-ID)以本次请求为准
+			for k, v := range entry.header {
+				if _, exists := h[k]; !exists {
+					h[k] = v
+				}
+			}
+			h.Set("X-Cache", "HIT")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+
+		w.Header().Set("X-Cache", "MISS")
+		cw := &cachingResponseWriter{ResponseWriter: w, before: w.Header().Clone()}
+		next.ServeHTTP(cw, r)
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		if cw.tooLarge || cw.status < 200 || cw.status >= 300 {
+			return
+		}
+		c.set(&apiCacheEntry{
+			key:     key,
+			status:  cw.status,
+			header:  cw.header,
+			body:    cw.buf.Bytes(),
+			expires: time.Now().Add(c.ttl),
+		})
+	})
+}
+
+// cachingResponseWriter 在写出响应的同时保存一份副本
+type cachingResponseWriter struct {
+	http.ResponseWriter
+	before   http.Header
+	header   http.Header
+	status   int
+	buf      bytes.Buffer
+	tooLarge bool
+}
+
+func (w *cachingResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.header = make(http.Header)
+		// 只保存处理函数自己设置的响应头
+		for k, v := range w.ResponseWriter.Header() {
+			if _, ok := w.before[k]; !ok {
+				w.header[k] = append([]string(nil), v...)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cachingResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.tooLarge {
+		if w.buf.Len()+len(p) > apiCacheMaxBody {
+			w.tooLarge = true
+			w.buf = bytes.Buffer{}
+		} else {
+			w.buf.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush 实现http.Flusher
+func (w *cachingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
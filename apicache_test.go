@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newCountingAPI 返回每次调用响应体都不同的处理函数，?status=可指定状态码
+func newCountingAPI() http.Handler {
+	calls := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		status := http.StatusOK
+		if s := r.URL.Query().Get("status"); s != "" {
+			status, _ = strconv.Atoi(s)
+		}
+		w.Header().Set("X-Handler", "yes")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "call %d", calls)
+	})
+}
+
+func TestAPICacheMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		ttl        time.Duration
+		maxEntries int
+		method     string
+		targets    []string
+		wantCache  []string // 每个请求期望的X-Cache
+		wantBodies []string
+	}{
+		{
+			name: "相同URL第二次命中", ttl: time.Hour, maxEntries: 8,
+			targets:    []string{"/api/get", "/api/get"},
+			wantCache:  []string{"MISS", "HIT"},
+			wantBodies: []string{"call 1", "call 1"},
+		},
+		{
+			name: "查询参数不同视为不同条目", ttl: time.Hour, maxEntries: 8,
+			targets:    []string{"/api/getjson?name=a", "/api/getjson?name=b", "/api/getjson?name=a"},
+			wantCache:  []string{"MISS", "MISS", "HIT"},
+			wantBodies: []string{"call 1", "call 2", "call 1"},
+		},
+		{
+			name: "超过TTL后重新请求", ttl: time.Nanosecond, maxEntries: 8,
+			targets:    []string{"/api/get", "/api/get"},
+			wantCache:  []string{"MISS", "MISS"},
+			wantBodies: []string{"call 1", "call 2"},
+		},
+		{
+			name: "超过maxEntries淘汰最久未使用的条目", ttl: time.Hour, maxEntries: 2,
+			targets:    []string{"/a", "/b", "/a", "/c", "/a", "/b"},
+			wantCache:  []string{"MISS", "MISS", "HIT", "MISS", "HIT", "MISS"},
+			wantBodies: []string{"call 1", "call 2", "call 1", "call 3", "call 1", "call 4"},
+		},
+		{
+			name: "非2xx响应不缓存", ttl: time.Hour, maxEntries: 8,
+			targets:    []string{"/api/get?status=500", "/api/get?status=500", "/api/get?status=404", "/api/get?status=201", "/api/get?status=201"},
+			wantCache:  []string{"MISS", "MISS", "MISS", "MISS", "HIT"},
+			wantBodies: []string{"call 1", "call 2", "call 3", "call 4", "call 4"},
+		},
+		{
+			name: "非GET请求不经过缓存", ttl: time.Hour, maxEntries: 8, method: http.MethodPost,
+			targets:    []string{"/api/get", "/api/get"},
+			wantCache:  []string{"", ""},
+			wantBodies: []string{"call 1", "call 2"},
+		},
+	}
+	for _, tt := range tests {
+		h := newAPICache(tt.ttl, tt.maxEntries).middleware(newCountingAPI())
+		method := tt.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		for i, target := range tt.targets {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+			if got := w.Header().Get("X-Cache"); got != tt.wantCache[i] {
+				t.Errorf("%s: request %d %s: X-Cache = %q, want %q", tt.name, i, target, got, tt.wantCache[i])
+			}
+			if got := w.Body.String(); got != tt.wantBodies[i] {
+				t.Errorf("%s: request %d %s: body = %q, want %q", tt.name, i, target, got, tt.wantBodies[i])
+			}
+		}
+	}
+}
+
+// 命中时回放状态码和处理函数设置的头，外层中间件设置的X-Request-ID以本次请求为准
+func TestAPICacheReplaysOnlyHandlerHeaders(t *testing.T) {
+	cache := newAPICache(time.Hour, 8)
+	api := cache.middleware(newCountingAPI())
+	requestID := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID++
+		w.Header().Set("X-Request-ID", strconv.Itoa(requestID))
+		api.ServeHTTP(w, r)
+	})
+
+	for i, want := range []string{"MISS", "HIT"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/get?status=202", nil))
+		if w.Header().Get("X-Cache") != want || w.Code != http.StatusAccepted {
+			t.Fatalf("request %d: X-Cache = %q, status = %d", i, w.Header().Get("X-Cache"), w.Code)
+		}
+		if got := w.Header().Values("X-Request-ID"); len(got) != 1 || got[0] != strconv.Itoa(i+1) {
+			t.Errorf("request %d: X-Request-ID = %q, want [%d]", i, got, i+1)
+		}
+		if got := w.Header().Get("X-Handler"); got != "yes" {
+			t.Errorf("request %d: X-Handler = %q", i, got)
+		}
+	}
+
+	entry, ok := cache.get("/api/get?status=202")
+	if !ok {
+		t.Fatal("响应未被缓存")
+	}
+	for _, k := range []string{"X-Request-Id", "X-Cache"} {
+		if _, stored := entry.header[k]; stored {
+			t.Errorf("缓存条目不应包含外层设置的 %s", k)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// normalizeBasePath 校验-base-path并去掉结尾的/，"/"或空串表示不使用前缀
+func normalizeBasePath(base string) (string, error) {
+	if base == "" || base == "/" {
+		return "", nil
+	}
+	if !strings.HasPrefix(base, "/") {
+		return "", fmt.Errorf("-base-path %q 必须以/开头", base)
+	}
+	return strings.TrimRight(base, "/"), nil
+}
+
+// basePathHandler 把整个服务挂载到base前缀下: 去掉前缀后交给next，
+// 访问不带/的base时301跳转补全/，前缀之外的路径返回404
+func basePathHandler(base string, next http.Handler) http.Handler {
+	stripped := http.StripPrefix(base, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == base:
+			target := base + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, base+"/"):
+			stripped.ServeHTTP(&basePathWriter{ResponseWriter: w, base: base}, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// basePathWriter 给处理函数生成的以/开头的重定向地址(如ServeMux补全/)加上前缀
+type basePathWriter struct {
+	http.ResponseWriter
+	base        string
+	wroteHeader bool
+}
+
+func (w *basePathWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if loc := w.Header().Get("Location"); strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") {
+			w.Header().Set("Location", w.base+loc)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *basePathWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *basePathWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 让http.ResponseController可以访问到底层的ResponseWriter
+func (w *basePathWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
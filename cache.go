@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// 未配置的扩展名使用的缓存策略，保守起见每次都向服务端验证
+const defaultCachePolicy = "no-cache"
+
+// 长期缓存策略，适用于文件名带hash的静态资源
+const immutableCachePolicy = "max-age=31536000, immutable"
+
+// defaultCachePolicies 返回内置的扩展名到Cache-Control的映射
+func defaultCachePolicies() map[string]string {
+	policies := map[string]string{
+		".html": "no-cache",
+		".htm":  "no-cache",
+	}
+	for _, ext := range []string{".js", ".mjs", ".css", ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".avif", ".ico", ".woff", ".woff2"} {
+		policies[ext] = immutableCachePolicy
+	}
+	return policies
+}
+
+// parseCacheSpecs 解析 -cache=.ext=policy 形式的参数并覆盖到policies上。
+// policy为纯数字时视为max-age秒数，否则原样作为Cache-Control的值
+func parseCacheSpecs(policies map[string]string, specs []string) error {
+	for _, spec := range specs {
+		ext, policy, ok := strings.Cut(spec, "=")
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		policy = strings.TrimSpace(policy)
+		if !ok || !strings.HasPrefix(ext, ".") || policy == "" {
+			return fmt.Errorf("无效的 -cache 参数 %q，格式应为 .ext=秒数 或 .ext=Cache-Control值", spec)
+		}
+		if seconds, err := strconv.Atoi(policy); err == nil {
+			policy = "max-age=" + strconv.Itoa(seconds)
+		}
+		policies[ext] = policy
+	}
+	return nil
+}
+
+// cacheControlHandler 按文件扩展名设置Cache-Control。只对成功的响应生效，
+// 避免把404之类的错误响应长期缓存在浏览器里
+func cacheControlHandler(policies map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ext := strings.ToLower(path.Ext(r.URL.Path))
+		if strings.HasSuffix(r.URL.Path, "/") {
+			ext = ".html"
+		}
+		policy, ok := policies[ext]
+		if !ok {
+			policy = defaultCachePolicy
+		}
+		next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, policy: policy}, r)
+	})
+}
+
+// cacheControlWriter 在写出状态码时根据结果决定是否添加Cache-Control
+type cacheControlWriter struct {
+	http.ResponseWriter
+	policy      string
+	wroteHeader bool
+}
+
+func (w *cacheControlWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		h := w.Header()
+		if code < http.StatusBadRequest && h.Get("Cache-Control") == "" {
+			h.Set("Cache-Control", w.policy)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheControlWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *cacheControlWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+type fileChecksum struct {
+	modTime time.Time
+	size    int64
+	sum     []byte
+}
+
+// checksumCache 缓存静态文件内容的SHA-256，文件大小或修改时间变化时重新计算。
+// -checksums的清单、Digest头和-etag-content的ETag都使用它
+type checksumCache struct {
+	fsys    http.FileSystem
+	mu      sync.Mutex
+	entries map[string]fileChecksum
+
+	// hidden 中的路径前缀(如-auth-prefix保护的目录)不列入/checksums.json
+	hidden []string
+	// /checksums.json的响应体，最多每checksumRescanInterval按修改时间检查一次文件变化
+	manifestMu   sync.Mutex
+	manifestJSON []byte
+	scannedAt    time.Time
+}
+
+// /checksums.json重新检查文件修改时间的最短间隔，期间直接返回上次生成的清单
+const checksumRescanInterval = 5 * time.Second
+
+func newChecksumCache(fsys http.FileSystem) *checksumCache {
+	return &checksumCache{fsys: fsys, entries: make(map[string]fileChecksum)}
+}
+
+// lookup 返回大小和修改时间都未变化的文件的缓存校验和
+func (c *checksumCache) lookup(name string, info os.FileInfo) ([]byte, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+		return e.sum, true
+	}
+	return nil, false
+}
+
+// get 返回文件的SHA-256，缓存失效时从r读取内容重新计算
+func (c *checksumCache) get(name string, info os.FileInfo, r io.Reader) ([]byte, error) {
+	if sum, ok := c.lookup(name, info); ok {
+		return sum, nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	sum := h.Sum(nil)
+
+	c.mu.Lock()
+	c.entries[name] = fileChecksum{modTime: info.ModTime(), size: info.Size(), sum: sum}
+	c.mu.Unlock()
+	return sum, nil
+}
+
+// manifest 遍历整个目录，返回路径到十六进制SHA-256的映射。以.开头的文件和目录不计入，
+// 只重新读取大小或修改时间变化过的文件
+func (c *checksumCache) manifest() (map[string]string, error) {
+	sums := make(map[string]string)
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		d, err := c.fsys.Open(dir)
+		if err != nil {
+			return err
+		}
+		infos, err := d.Readdir(-1)
+		d.Close()
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			if strings.HasPrefix(info.Name(), ".") {
+				continue
+			}
+			name := path.Join(dir, info.Name())
+			if info.IsDir() {
+				if err := walk(name); err != nil {
+					return err
+				}
+				continue
+			}
+			if !info.Mode().IsRegular() {
+				continue
+			}
+			if sum, ok := c.lookup(name, info); ok {
+				sums[name] = hex.EncodeToString(sum)
+				continue
+			}
+			f, err := c.fsys.Open(name)
+			if err != nil {
+				return err
+			}
+			sum, err := c.get(name, info, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			sums[name] = hex.EncodeToString(sum)
+		}
+		return nil
+	}
+	if err := walk("/"); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// writeManifest 把校验和清单写入文件
+func (c *checksumCache) writeManifest(file string) (int, error) {
+	sums, err := c.manifest()
+	if err != nil {
+		return 0, err
+	}
+	data, err := json.MarshalIndent(sums, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	return len(sums), os.WriteFile(file, append(data, '\n'), 0o644)
+}
+
+// publicManifest 返回/checksums.json的响应体，距上次检查不足checksumRescanInterval时直接复用
+func (c *checksumCache) publicManifest() ([]byte, error) {
+	c.manifestMu.Lock()
+	defer c.manifestMu.Unlock()
+	if c.manifestJSON != nil && time.Since(c.scannedAt) < checksumRescanInterval {
+		return c.manifestJSON, nil
+	}
+	sums, err := c.manifest()
+	if err != nil {
+		return nil, err
+	}
+	for name := range sums {
+		for _, prefix := range c.hidden {
+			if strings.HasPrefix(name, prefix) {
+				delete(sums, name)
+				break
+			}
+		}
+	}
+	data, err := json.Marshal(sums)
+	if err != nil {
+		return nil, err
+	}
+	c.manifestJSON, c.scannedAt = append(data, '\n'), time.Now()
+	return c.manifestJSON, nil
+}
+
+// ServeHTTP 处理/checksums.json，返回校验和清单
+func (c *checksumCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := c.publicManifest()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "计算校验和失败: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
+// digestHandler 为静态文件设置 Digest: sha-256=<base64> 响应头，
+// 值为原始文件内容的校验和，客户端无需额外请求即可校验下载的文件
+func digestHandler(cache *checksumCache, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setDigest(w.Header(), cache, path.Clean("/"+r.URL.Path))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setDigest 为普通文件name设置Digest头，目录和不存在的文件不做处理
+func setDigest(h http.Header, cache *checksumCache, name string) {
+	f, err := cache.fsys.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		if sum, err := cache.get(name, info, f); err == nil {
+			h.Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum))
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPublicManifestHidesProtectedPrefixes(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "admin/secret.txt", ".hidden"} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := newChecksumCache(http.Dir(root))
+	c.hidden = []string{"/admin"}
+
+	data, err := c.publicManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sums map[string]string
+	if err := json.Unmarshal(data, &sums); err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 1 || sums["/a.txt"] == "" {
+		t.Errorf("manifest = %v, want only /a.txt", sums)
+	}
+
+	// 检查间隔内新增文件不重新扫描
+	os.WriteFile(filepath.Join(root, "b.txt"), []byte("b"), 0o644)
+	again, _ := c.publicManifest()
+	if string(again) != string(data) {
+		t.Errorf("manifest rescanned within %s", checksumRescanInterval)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// 小于该字节数的响应不值得压缩
+const compressMinSize = 1024
+
+// 实时压缩使用的brotli级别，兼顾压缩率和CPU开销
+const brotliLevel = 5
+
+// 这些类型本身已经是压缩格式，再压缩只会浪费CPU
+var incompressibleTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/octet-stream",
+}
+
+// compressEncoder gzip.Writer和brotli.Writer的公共方法
+type compressEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// encoderPool 按编码名称复用压缩器
+type encoderPool struct {
+	name string
+	pool *sync.Pool
+}
+
+var (
+	brotliPool = encoderPool{"br", &sync.Pool{New: func() any { return brotli.NewWriterLevel(nil, brotliLevel) }}}
+	gzipPool   = encoderPool{"gzip", &sync.Pool{New: func() any { return gzip.NewWriter(nil) }}}
+)
+
+// compressHandler 包装任意处理函数，按Accept-Encoding协商使用br或gzip压缩响应，
+// 都不支持时原样返回
+func compressHandler(next http.Handler) http.Handler {
+	return negotiatingHandler([]encoderPool{brotliPool, gzipPool}, next)
+}
+
+// gzipHandler 与compressHandler相同，但只使用gzip压缩，供不需要br的场景使用
+func gzipHandler(next http.Handler) http.Handler {
+	return negotiatingHandler([]encoderPool{gzipPool}, next)
+}
+
+// negotiatingHandler 在encoders中协商客户端可接受的编码并压缩响应，协商时按顺序优先选择靠前的编码
+func negotiatingHandler(encoders []encoderPool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addVary(w.Header(), "Accept-Encoding")
+		// 范围请求的偏移是针对原始内容的，不能压缩
+		if r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		encoding, pool := negotiateEncoding(r, encoders)
+		if pool == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w, status: http.StatusOK, encoding: encoding, pool: pool}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding 选出encoders中客户端可接受且q值最高的编码，q值相同时优先靠前的编码
+func negotiateEncoding(r *http.Request, encoders []encoderPool) (string, *sync.Pool) {
+	bestQ := 0.0
+	var bestName string
+	var bestPool *sync.Pool
+	for _, enc := range encoders {
+		if q := encodingQuality(r, enc.name); q > bestQ {
+			bestQ, bestName, bestPool = q, enc.name, enc.pool
+		}
+	}
+	return bestName, bestPool
+}
+
+// encodingQuality 返回Accept-Encoding中该编码的q值，未列出时按*处理，都没有时为0
+func encodingQuality(r *http.Request, encoding string) float64 {
+	wildcard := 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.TrimSpace(name)
+		q := 1.0
+		if s, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(s, 64); err == nil {
+				q = v
+			}
+		}
+		switch {
+		case strings.EqualFold(name, encoding):
+			return q
+		case name == "*":
+			wildcard = q
+		}
+	}
+	return wildcard
+}
+
+// compressResponseWriter 先缓冲响应的开头部分，等足以判断类型和大小后再决定是否压缩
+type compressResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	encoding    string
+	pool        *sync.Pool
+	enc         compressEncoder
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	// 非200的响应(304、206、错误页等)不压缩，直接写出
+	if code != http.StatusOK {
+		w.decide(false)
+	}
+}
+
+func (w *compressResponseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < compressMinSize {
+			return len(p), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// decide 确定是否压缩并写出响应头和已缓冲的数据
+func (w *compressResponseWriter) decide(bigEnough bool) error {
+	w.decided = true
+	h := w.Header()
+	// 压缩后net/http就无法再嗅探内容类型，这里提前按原始数据确定
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if bigEnough && w.status == http.StatusOK && h.Get("Content-Encoding") == "" && compressibleType(h.Get("Content-Type")) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		weakenValidators(h)
+		w.enc = w.pool.Get().(compressEncoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	// 304没有响应体，但对应的200会被压缩，验证器必须与之一致；
+	// 预压缩文件的ETag本身就对应压缩版本，保持不变
+	if w.status == http.StatusNotModified && !isSidecarETag(h.Get("ETag")) {
+		weakenValidators(h)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// Flush 实现http.Flusher，便于流式响应及时推送给客户端
+func (w *compressResponseWriter) Flush() {
+	if !w.decided {
+		w.decide(len(w.buf) >= compressMinSize)
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close 写出剩余数据并归还压缩器
+func (w *compressResponseWriter) Close() error {
+	if !w.decided {
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+	if w.enc == nil {
+		return nil
+	}
+	err := w.enc.Close()
+	w.pool.Put(w.enc)
+	w.enc = nil
+	return err
+}
+
+// weakenValidators ETag和Digest是按原始内容计算的，压缩后的字节不同：强ETag降为弱ETag
+// (仍可用于If-None-Match的弱比较)，Digest不再适用
+func weakenValidators(h http.Header) {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	h.Del("Digest")
+}
+
+func compressibleType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, t := range incompressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressHandlerWeakensETag(t *testing.T) {
+	body := strings.Repeat("console.log('hello');\n", 200)
+	h := compressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript")
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Digest", "sha-256=xyz")
+		w.Write([]byte(body))
+	}))
+
+	tests := []struct {
+		acceptEncoding string
+		wantEncoding   string
+		wantETag       string
+		wantDigest     string
+	}{
+		{"", "", `"abc"`, "sha-256=xyz"},
+		{"gzip", "gzip", `W/"abc"`, ""},
+		{"br, gzip", "br", `W/"abc"`, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+		if tt.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		got := w.Header()
+		if got.Get("Content-Encoding") != tt.wantEncoding || got.Get("ETag") != tt.wantETag || got.Get("Digest") != tt.wantDigest {
+			t.Errorf("Accept-Encoding %q: encoding=%q etag=%q digest=%q, want %q %q %q", tt.acceptEncoding,
+				got.Get("Content-Encoding"), got.Get("ETag"), got.Get("Digest"), tt.wantEncoding, tt.wantETag, tt.wantDigest)
+		}
+	}
+}
+
+// 协商了压缩编码的304应返回与压缩的200相同的弱ETag，预压缩文件的ETag保持不变
+func TestCompressHandlerWeakensETagOnNotModified(t *testing.T) {
+	tests := []struct {
+		etag     string
+		wantETag string
+	}{
+		{`"abc"`, `W/"abc"`},
+		{`W/"abc"`, `W/"abc"`},
+		{`"3e8-17f-gzip"`, `"3e8-17f-gzip"`},
+	}
+	for _, tt := range tests {
+		h := compressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", tt.etag)
+			w.WriteHeader(http.StatusNotModified)
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusNotModified || w.Header().Get("ETag") != tt.wantETag {
+			t.Errorf("ETag %s: got %d %s, want 304 %s", tt.etag, w.Code, w.Header().Get("ETag"), tt.wantETag)
+		}
+	}
+}
+
+// gzipHandler只使用gzip，客户端只接受br时原样返回
+func TestGzipHandlerOnlyGzip(t *testing.T) {
+	body := strings.Repeat("<p>hello</p>\n", 200)
+	h := gzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body))
+	}))
+	for acceptEncoding, want := range map[string]string{
+		"br":          "",
+		"br, gzip":    "gzip",
+		"gzip;q=0.5":  "gzip",
+		"br;q=1, *":   "gzip",
+		"identity":    "",
+		"gzip;q=0, *": "",
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Header().Get("Content-Encoding"); got != want {
+			t.Errorf("Accept-Encoding %q: Content-Encoding = %q, want %q", acceptEncoding, got, want)
+		}
+	}
+}
+
+// 同时启用实时压缩和预压缩时Vary: Accept-Encoding只出现一次
+func TestFileHandlerSingleVary(t *testing.T) {
+	root := t.TempDir()
+	body := strings.Repeat("body { color: red; }\n", 200)
+	if err := os.WriteFile(filepath.Join(root, "app.css"), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := newFileHandler(http.Dir(root), fileServerOptions{
+		indexes:       []string{"index.html"},
+		cachePolicies: defaultCachePolicies(),
+		compress:      true,
+		precompressed: true,
+	})
+	for _, acceptEncoding := range []string{"", "gzip"} {
+		r := httptest.NewRequest(http.MethodGet, "/app.css", nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Header().Values("Vary"); len(got) != 1 || got[0] != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: Vary = %q, want [Accept-Encoding]", acceptEncoding, got)
+		}
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{}
+	h.Set("Vary", "Origin, accept-encoding")
+	addVary(h, "Accept-Encoding")
+	addVary(h, "Accept-Language")
+	addVary(h, "Accept-Language")
+	if got := h.Values("Vary"); len(got) != 2 || got[1] != "Accept-Language" {
+		t.Errorf("Vary = %q", got)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Config 配置文件的内容，每一项的json名称与对应的命令行参数同名。
+// 未出现的项保持为nil，不会覆盖默认值；时长使用"15s"这样的字符串
+type Config struct {
+	Addr              *string  `json:"addr"`
+	BasePath          *string  `json:"base-path"`
+	SocketMode        *uint    `json:"socket-mode"`
+	Dir               *string  `json:"dir"`
+	Embed             *bool    `json:"embed"`
+	Listing           *bool    `json:"listing"`
+	NotFoundPage      *string  `json:"404-page"`
+	SPA               *bool    `json:"spa"`
+	Mount             []string `json:"mount"`
+	Index             *string  `json:"index"`
+	MountIndex        []string `json:"mount-index"`
+	File              []string `json:"file"`
+	Delay             *string  `json:"delay"`
+	AllowDelayParam   *bool    `json:"allow-delay-param"`
+	ETagContent       *bool    `json:"etag-content"`
+	Cache             []string `json:"cache"`
+	Mime              []string `json:"mime"`
+	Watch             *bool    `json:"watch"`
+	Compress          *bool    `json:"compress"`
+	Precompressed     *bool    `json:"precompressed"`
+	CORSOrigin        *string  `json:"cors-origin"`
+	Cert              *string  `json:"cert"`
+	Key               *string  `json:"key"`
+	RedirectHTTP      *bool    `json:"redirect-http"`
+	H2C               *bool    `json:"h2c"`
+	AuthUser          *string  `json:"auth-user"`
+	AuthPass          *string  `json:"auth-pass"`
+	AuthPrefix        *string  `json:"auth-prefix"`
+	Allow             *string  `json:"allow"`
+	Deny              *string  `json:"deny"`
+	TrustProxy        *bool    `json:"trust-proxy"`
+	TrustedProxies    *string  `json:"trusted-proxies"`
+	Rate              *float64 `json:"rate"`
+	Burst             *int     `json:"burst"`
+	Proxy             *string  `json:"proxy"`
+	Greeting          *string  `json:"greeting"`
+	MaxUpload         *int64   `json:"max-upload"`
+	Readonly          *bool    `json:"readonly"`
+	EchoMaxBody       *int64   `json:"echo-max-body"`
+	MaxResponse       *int64   `json:"max-response"`
+	APICacheTTL       *string  `json:"api-cache-ttl"`
+	MaintenanceFile   *string  `json:"maintenance-file"`
+	Checksums         *string  `json:"checksums"`
+	ChecksumsEndpoint *bool    `json:"checksums-endpoint"`
+	I18n              *bool    `json:"i18n"`
+	I18nDefault       *string  `json:"i18n-default"`
+	NoSniff           *bool    `json:"nosniff"`
+	FrameOptions      *string  `json:"frame-options"`
+	ReferrerPolicy    *string  `json:"referrer-policy"`
+	CSP               *string  `json:"csp"`
+	LogFormat         *string  `json:"log-format"`
+	Metrics           *bool    `json:"metrics"`
+	ReadTimeout       *string  `json:"read-timeout"`
+	ReadHeaderTimeout *string  `json:"read-header-timeout"`
+	WriteTimeout      *string  `json:"write-timeout"`
+	IdleTimeout       *string  `json:"idle-timeout"`
+	ShutdownTimeout   *string  `json:"shutdown-timeout"`
+	ShutdownDelay     *string  `json:"shutdown-delay"`
+}
+
+// loadConfig 读取并校验JSON配置文件，出现未知的配置项时报错
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("配置文件 %s 第 %d 字节附近格式错误: %w", path, syntaxErr.Offset, err)
+		}
+		return nil, fmt.Errorf("配置文件 %s 解析失败(请检查配置项名称和类型): %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("配置文件 %s 校验失败: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// validate 检查无法由JSON类型保证的取值
+func (cfg *Config) validate() error {
+	durations := map[string]*string{
+		"read-timeout":        cfg.ReadTimeout,
+		"read-header-timeout": cfg.ReadHeaderTimeout,
+		"write-timeout":       cfg.WriteTimeout,
+		"idle-timeout":        cfg.IdleTimeout,
+		"shutdown-timeout":    cfg.ShutdownTimeout,
+		"shutdown-delay":      cfg.ShutdownDelay,
+		"delay":               cfg.Delay,
+		"api-cache-ttl":       cfg.APICacheTTL,
+	}
+	for name, v := range durations {
+		if v == nil {
+			continue
+		}
+		if _, err := time.ParseDuration(*v); err != nil {
+			return fmt.Errorf("%s 的值 %q 不是有效的时长: %w", name, *v, err)
+		}
+	}
+	if cfg.Addr != nil && *cfg.Addr == "" {
+		return errors.New("addr 不能为空")
+	}
+	if cfg.Dir != nil && *cfg.Dir == "" {
+		return errors.New("dir 不能为空")
+	}
+	if cfg.LogFormat != nil && *cfg.LogFormat != "text" && *cfg.LogFormat != "json" {
+		return fmt.Errorf("log-format 的值 %q 无效，可选 text 或 json", *cfg.LogFormat)
+	}
+	return nil
+}
+
+// 参数取值的来源，用于-check输出
+const (
+	sourceFlag   = "命令行"
+	sourceEnv    = "环境变量"
+	sourceConfig = "配置文件"
+)
+
+// apply 把配置项写入同名的命令行参数，已由命令行或环境变量指定的参数优先，不会被覆盖
+func (cfg *Config) apply(fs *flag.FlagSet, sources map[string]string) error {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("json")
+		field := v.Field(i)
+		if _, set := sources[name]; field.IsNil() || set {
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("配置项 %s 没有对应的命令行参数", name)
+		}
+
+		var values []string
+		if field.Kind() == reflect.Slice {
+			for j := 0; j < field.Len(); j++ {
+				values = append(values, fmt.Sprint(field.Index(j).Interface()))
+			}
+		} else {
+			values = []string{fmt.Sprint(field.Elem().Interface())}
+		}
+		for _, value := range values {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("配置项 %s 的值 %q 无效: %w", name, value, err)
+			}
+		}
+		sources[name] = sourceConfig
+	}
+	return nil
+}
+
+// values 返回配置文件中出现的配置项及其参数形式的取值，多个值用逗号连接
+func (cfg *Config) values() map[string]string {
+	values := make(map[string]string)
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.IsNil() {
+			continue
+		}
+		if field.Kind() == reflect.Slice {
+			var items []string
+			for j := 0; j < field.Len(); j++ {
+				items = append(items, fmt.Sprint(field.Index(j).Interface()))
+			}
+			values[t.Field(i).Tag.Get("json")] = strings.Join(items, ",")
+		} else {
+			values[t.Field(i).Tag.Get("json")] = fmt.Sprint(field.Elem().Interface())
+		}
+	}
+	return values
+}
+
+// explicitFlags 返回命令行中显式指定过的参数，值为取值来源
+func explicitFlags(fs *flag.FlagSet) map[string]string {
+	sources := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		sources[f.Name] = sourceFlag
+	})
+	return sources
+}
+
+// 所有环境变量共用的前缀，避免CHECK、PROXY这类通用名称被容器中已有的变量误触发
+const envPrefix = "HTTP_SERVER_"
+
+// 兼容的环境变量别名，只在没有设置带前缀的变量时使用
+var envAliases = map[string]string{
+	"dir":      "STATIC_DIR",
+	"404-page": "NOT_FOUND_PAGE",
+	"cert":     "TLS_CERT",
+	"key":      "TLS_KEY",
+}
+
+// envName 返回参数对应的环境变量名: 加上HTTP_SERVER_前缀，转为大写并把-替换为_，
+// 如 cors-origin -> HTTP_SERVER_CORS_ORIGIN
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// lookupEnv 依次查找参数对应的环境变量和别名，返回取值及实际使用的变量名
+func lookupEnv(flagName string) (value, name string, ok bool) {
+	name = envName(flagName)
+	if value, ok = os.LookupEnv(name); ok {
+		return value, name, true
+	}
+	if alias, has := envAliases[flagName]; has {
+		if value, ok = os.LookupEnv(alias); ok {
+			return value, alias, true
+		}
+	}
+	return "", "", false
+}
+
+// applyEnv 用环境变量填充命令行中没有显式指定的参数，并记录到sources中，
+// 使配置文件不会再覆盖。可重复的参数(-mount等)在环境变量中用;分隔多个值
+func applyEnv(fs *flag.FlagSet, sources map[string]string) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, set := sources[f.Name]; err != nil || set {
+			return
+		}
+		value, name, ok := lookupEnv(f.Name)
+		if !ok {
+			return
+		}
+		values := []string{value}
+		if _, multi := f.Value.(*multiFlag); multi {
+			values = strings.Split(value, ";")
+		}
+		for _, v := range values {
+			if serr := fs.Set(f.Name, v); serr != nil {
+				err = fmt.Errorf("环境变量 %s 的值 %q 无效: %w", name, v, serr)
+				return
+			}
+		}
+		sources[f.Name] = sourceEnv
+	})
+	return err
+}
+
+// 输出有效配置时需要隐藏取值的参数
+var secretFlags = map[string]bool{"auth-pass": true}
+
+// printEffectiveConfig 输出所有参数的最终取值及来源
+func printEffectiveConfig(w io.Writer, fs *flag.FlagSet, sources map[string]string) {
+	fmt.Fprintln(w, "有效配置:")
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if secretFlags[f.Name] && value != "" {
+			value = "******"
+		}
+		source, ok := sources[f.Name]
+		if !ok {
+			source = "默认值"
+		}
+		fmt.Fprintf(w, "  -%s = %q (%s)\n", f.Name, value, source)
+	})
+}
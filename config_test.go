@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	for flagName, want := range map[string]string{
+		"addr":        "HTTP_SERVER_ADDR",
+		"cors-origin": "HTTP_SERVER_CORS_ORIGIN",
+		"check":       "HTTP_SERVER_CHECK",
+		"404-page":    "HTTP_SERVER_404_PAGE",
+	} {
+		if got := envName(flagName); got != want {
+			t.Errorf("envName(%q) = %q, want %q", flagName, got, want)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("CHECK", "true")
+	t.Setenv("RATE", "5")
+	t.Setenv("HTTP_SERVER_ADDR", ":9000")
+	t.Setenv("STATIC_DIR", "/srv/alias")
+	t.Setenv("TLS_CERT", "alias.pem")
+	t.Setenv("HTTP_SERVER_CERT", "prefixed.pem")
+	t.Setenv("HTTP_SERVER_MOUNT", "/a=./a;/b=./b")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	check := fs.Bool("check", false, "")
+	rate := fs.Float64("rate", 0, "")
+	addr := fs.String("addr", ":8088", "")
+	dir := fs.String("dir", "./public", "")
+	cert := fs.String("cert", "", "")
+	var mounts multiFlag
+	fs.Var(&mounts, "mount", "")
+
+	sources := map[string]string{}
+	if err := applyEnv(fs, sources); err != nil {
+		t.Fatal(err)
+	}
+	if *check || *rate != 0 {
+		t.Errorf("不带前缀的变量不应生效: check=%v rate=%v", *check, *rate)
+	}
+	if *addr != ":9000" || *dir != "/srv/alias" || *cert != "prefixed.pem" {
+		t.Errorf("addr=%q dir=%q cert=%q", *addr, *dir, *cert)
+	}
+	if len(mounts) != 2 || mounts[1] != "/b=./b" {
+		t.Errorf("mount = %q", mounts)
+	}
+	if sources["addr"] != sourceEnv || sources["check"] != "" {
+		t.Errorf("sources = %v", sources)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// contentTypeOverrides 强制指定的Content-Type，按扩展名或文件名/路径结尾匹配
+type contentTypeOverrides struct {
+	byExt    map[string]string
+	bySuffix []contentTypeSuffix
+}
+
+type contentTypeSuffix struct {
+	suffix      string
+	contentType string
+}
+
+// parseMimeSpecs 解析 -mime 参数: 以.开头的按扩展名匹配(如 .wasm=application/wasm)，
+// 其他按文件名或路径结尾匹配(如 package=application/gzip 或 download/package=...)
+func parseMimeSpecs(specs []string) (*contentTypeOverrides, error) {
+	o := &contentTypeOverrides{byExt: make(map[string]string)}
+	for _, spec := range specs {
+		pattern, contentType, ok := strings.Cut(spec, "=")
+		pattern, contentType = strings.TrimSpace(pattern), strings.TrimSpace(contentType)
+		if !ok || pattern == "" || contentType == "" {
+			return nil, fmt.Errorf("无效的 -mime 参数 %q，格式应为 文件名或.ext=类型", spec)
+		}
+		if strings.HasPrefix(pattern, ".") && !strings.Contains(pattern, "/") {
+			o.byExt[strings.ToLower(pattern)] = contentType
+			continue
+		}
+		o.bySuffix = append(o.bySuffix, contentTypeSuffix{
+			suffix:      "/" + strings.TrimPrefix(pattern, "/"),
+			contentType: contentType,
+		})
+	}
+	return o, nil
+}
+
+// lookup 返回name对应的强制类型，文件名匹配优先于扩展名
+func (o *contentTypeOverrides) lookup(name string) (string, bool) {
+	for _, s := range o.bySuffix {
+		if strings.HasSuffix(name, s.suffix) {
+			return s.contentType, true
+		}
+	}
+	contentType, ok := o.byExt[strings.ToLower(path.Ext(name))]
+	return contentType, ok
+}
+
+// contentTypeHandler 在交给文件服务器之前设置Content-Type，
+// ServeContent发现已有Content-Type时不会再按扩展名或内容嗅探
+func contentTypeHandler(o *contentTypeOverrides, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType, ok := o.lookup(path.Clean("/" + r.URL.Path)); ok {
+			w.Header().Set("Content-Type", contentType)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
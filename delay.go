@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// 通过查询参数指定的延迟上限，避免单个请求长时间占用连接
+const maxDelayParam = time.Minute
+
+// delayMiddleware 在处理请求前人为增加延迟，用于测试前端的加载状态和超时逻辑。
+// allowParam为true时允许用?_delay=1s覆盖默认延迟；客户端断开时立即放弃等待
+func delayMiddleware(delay time.Duration, allowParam bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := delay
+		if allowParam {
+			if v := r.URL.Query().Get("_delay"); v != "" {
+				if pd, err := time.ParseDuration(v); err == nil && pd >= 0 {
+					d = min(pd, maxDelayParam)
+				}
+			}
+		}
+		if d > 0 {
+			timer := time.NewTimer(d)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// 编译时内嵌的静态资源，使用-embed参数时无需外部public/目录
+//
+//go:embed public
+var embeddedFS embed.FS
+
+// embeddedPublic 返回去掉public/前缀的内嵌文件系统，URL与磁盘模式保持一致
+func embeddedPublic() (http.FileSystem, error) {
+	sub, err := fs.Sub(embeddedFS, "public")
+	if err != nil {
+		return nil, err
+	}
+	return http.FS(sub), nil
+}
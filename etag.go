@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+)
+
+// etagHandler 为静态文件设置强ETag。FileServer内部的ServeContent
+// 会根据已设置的ETag处理If-None-Match并返回304。目录请求的ETag由dirIndexHandler按选中的首页设置
+func etagHandler(fsys http.FileSystem, hashes *checksumCache, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setETag(w.Header(), fsys, hashes, path.Clean("/"+r.URL.Path))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setETag 为普通文件name设置ETag，默认由文件大小和修改时间生成，
+// hashes不为nil时使用其中缓存的内容SHA-256(与Digest头共用)。目录和不存在的文件不做处理
+func setETag(h http.Header, fsys http.FileSystem, hashes *checksumCache, name string) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	if hashes != nil {
+		if sum, err := hashes.get(name, info, f); err == nil {
+			h.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+		}
+		return
+	}
+	h.Set("ETag", fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano()))
+}
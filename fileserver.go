@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// noListingFS 包装http.FileSystem，禁止访问没有首页文件的目录，避免泄露目录结构
+type noListingFS struct {
+	fs        http.FileSystem
+	indexes   []string
+	localized bool // 启用-i18n时，只有index.en.html这样的本地化首页的目录也可以访问
+}
+
+func (nfs noListingFS) Open(name string) (http.File, error) {
+	f, err := nfs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !info.IsDir() {
+		return f, nil
+	}
+
+	// 目录下存在任一首页文件时正常处理，否则按不存在处理返回404
+	if index, _, ok := findIndex(nfs.fs, name, nfs.indexes); ok {
+		index.Close()
+		return f, nil
+	}
+	if nfs.localized {
+		if index, _, _, ok := findAnyLocalizedIndex(nfs.fs, name, nfs.indexes); ok {
+			index.Close()
+			return f, nil
+		}
+	}
+	f.Close()
+	return nil, os.ErrNotExist
+}
+
+// findIndex 按顺序查找目录dir下第一个存在的首页文件，调用方负责关闭返回的文件
+func findIndex(fsys http.FileSystem, dir string, indexes []string) (http.File, os.FileInfo, bool) {
+	for _, index := range indexes {
+		f, err := fsys.Open(path.Join(dir, index))
+		if err != nil {
+			continue
+		}
+		info, err := f.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			f.Close()
+			continue
+		}
+		return f, info, true
+	}
+	return nil, nil, false
+}
+
+// dirIndexHandler 请求目录时按indexes的顺序返回第一个存在的首页文件，
+// 都不存在时交给next，由FileServer生成目录列表或返回404。
+// langs不为nil时优先返回与Accept-Language匹配的本地化首页，并设置Content-Language；
+// 目录下只有本地化首页而没有匹配的语言时返回其中任一个，而不是退回目录列表或404。
+// validators不为nil时用选中首页的路径调用它，设置ETag、Digest等按文件计算的响应头
+func dirIndexHandler(fsys http.FileSystem, indexes []string, langs *languageNegotiator, validators func(http.Header, string), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 不以/结尾的目录请求由FileServer负责重定向补全/
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		name := path.Clean("/" + r.URL.Path)
+		if langs != nil {
+			addVary(w.Header(), "Accept-Language")
+			if f, info, lang, ok := findLocalizedIndex(fsys, name, indexes, langs.candidates(r)); ok {
+				defer f.Close()
+				w.Header().Set("Content-Language", lang)
+				if validators != nil {
+					validators(w.Header(), path.Join(name, info.Name()))
+				}
+				http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+				return
+			}
+		}
+		f, info, ok := findIndex(fsys, name, indexes)
+		if !ok && langs != nil {
+			var lang string
+			if f, info, lang, ok = findAnyLocalizedIndex(fsys, name, indexes); ok {
+				w.Header().Set("Content-Language", lang)
+			}
+		}
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer f.Close()
+		if validators != nil {
+			validators(w.Header(), path.Join(name, info.Name()))
+		}
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	})
+}
+
+// notFoundPageHandler 在请求的文件不存在时返回静态目录中的自定义404页面，
+// 页面本身不存在时退回FileServer默认的404
+func notFoundPageHandler(fsys http.FileSystem, page string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := fsys.Open(path.Clean("/" + r.URL.Path))
+		if err == nil {
+			f.Close()
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !errors.Is(err, os.ErrNotExist) || !serveNotFoundPage(w, fsys, page) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// serveNotFoundPage 以404状态写出自定义页面，页面不可用时返回false
+func serveNotFoundPage(w http.ResponseWriter, fsys http.FileSystem, page string) bool {
+	f, err := fsys.Open(path.Clean("/" + page))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	io.Copy(w, f)
+	return true
+}
+
+// spaHandler 为单页应用提供前端路由回退: 不存在的路径返回根目录的首页文件，
+// 但带扩展名的资源(.js/.css等)和/api/下的路径仍然返回404，便于发现失效的资源链接
+func spaHandler(fsys http.FileSystem, indexes []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		f, err := fsys.Open(name)
+		if err == nil {
+			f.Close()
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !errors.Is(err, os.ErrNotExist) || strings.HasPrefix(name, "/api/") || !isRouteLike(name) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		index, info, ok := findIndex(fsys, "/", indexes)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer index.Close()
+		http.ServeContent(w, r, info.Name(), info.ModTime(), index)
+	})
+}
+
+// isRouteLike 判断路径看起来是前端路由而不是静态资源
+func isRouteLike(name string) bool {
+	ext := path.Ext(name)
+	return ext == "" || ext == ".html" || ext == ".htm"
+}
+
+// rangeFileHandler 对普通文件直接使用http.ServeContent输出，由其处理Range请求，
+// 返回206和正确的Content-Range，支持多段范围和bytes=500-这样的开放范围，便于视频拖动播放。
+// 目录及index.html交给next(FileServer)处理，以保留其重定向和首页逻辑
+func rangeFileHandler(fsys http.FileSystem, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") || path.Base(name) == "index.html" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		f, err := fsys.Open(name)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	})
+}
+
+// fileServerOptions 静态文件处理链的配置，所有挂载点共用
+type fileServerOptions struct {
+	listing       bool
+	indexes       []string
+	notFoundPage  string
+	spa           bool
+	etagContent   bool
+	cachePolicies map[string]string
+	contentTypes  *contentTypeOverrides
+	liveReloadURL string // 为空表示不注入livereload脚本
+	compress      bool
+	precompressed bool
+	checksums     *checksumCache      // 为nil表示不设置Digest头
+	i18n          *languageNegotiator // 为nil表示不按Accept-Language选择首页
+}
+
+// newFileHandler 基于fsys构建完整的静态文件处理链
+func newFileHandler(fsys http.FileSystem, opts fileServerOptions) http.Handler {
+	if !opts.listing {
+		fsys = noListingFS{fs: fsys, indexes: opts.indexes, localized: opts.i18n != nil}
+	}
+	// 使用FileServer处理静态文件请求
+	var h http.Handler = http.FileServer(fsys)
+	// 普通文件直接通过ServeContent输出，完整支持Range请求
+	h = rangeFileHandler(fsys, h)
+	// 按内容计算的ETag和Digest头共用同一份SHA-256缓存
+	var etagHashes *checksumCache
+	if opts.etagContent {
+		etagHashes = opts.checksums
+		if etagHashes == nil {
+			etagHashes = newChecksumCache(fsys)
+		}
+	}
+	// 目录请求实际返回的是首页文件，ETag和Digest按选中的首页计算
+	validators := func(header http.Header, name string) {
+		setETag(header, fsys, etagHashes, name)
+		if opts.checksums != nil {
+			setDigest(header, opts.checksums, name)
+		}
+	}
+	// 目录请求按-index的顺序查找首页文件
+	h = dirIndexHandler(fsys, opts.indexes, opts.i18n, validators, h)
+	// 设置ETag，让重复访问的客户端可以得到304
+	h = etagHandler(fsys, etagHashes, h)
+	if opts.checksums != nil {
+		h = digestHandler(opts.checksums, h)
+	}
+	// 优先返回预压缩的.br/.gz文件
+	if opts.precompressed {
+		h = precompressedHandler(fsys, h)
+	}
+	if opts.notFoundPage != "" {
+		h = notFoundPageHandler(fsys, opts.notFoundPage, h)
+	}
+	if opts.spa {
+		h = spaHandler(fsys, opts.indexes, h)
+	}
+	// 按-mime配置强制Content-Type
+	if opts.contentTypes != nil {
+		h = contentTypeHandler(opts.contentTypes, h)
+	}
+	// 按扩展名设置浏览器缓存策略
+	h = cacheControlHandler(opts.cachePolicies, h)
+	// 在HTML中注入自动刷新脚本，需在压缩之前处理
+	if opts.liveReloadURL != "" {
+		h = liveReloadInjector(opts.liveReloadURL, h)
+	}
+	// 按需br/gzip压缩
+	if opts.compress {
+		h = compressHandler(h)
+	}
+	return h
+}
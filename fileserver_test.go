@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newRangeTestFS 创建包含1000字节video.mp4的临时目录，第i个字节为i%256
+func newRangeTestFS(t *testing.T) (http.FileSystem, []byte) {
+	t.Helper()
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i % 256)
+	}
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "video.mp4"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return http.Dir(root), data
+}
+
+func TestRangeFileHandlerSingleRange(t *testing.T) {
+	fsys, data := newRangeTestFS(t)
+	h := rangeFileHandler(fsys, http.NotFoundHandler())
+
+	tests := []struct {
+		rangeHeader  string
+		wantStatus   int
+		contentRange string
+		start, end   int // 期望的响应体为data[start:end]
+	}{
+		{"", http.StatusOK, "", 0, 1000},
+		{"bytes=0-99", http.StatusPartialContent, "bytes 0-99/1000", 0, 100},
+		{"bytes=500-", http.StatusPartialContent, "bytes 500-999/1000", 500, 1000},
+		{"bytes=-100", http.StatusPartialContent, "bytes 900-999/1000", 900, 1000},
+		{"bytes=990-2000", http.StatusPartialContent, "bytes 990-999/1000", 990, 1000},
+		{"bytes=2000-", http.StatusRequestedRangeNotSatisfiable, "bytes */1000", 0, 0},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/video.mp4", nil)
+		if tt.rangeHeader != "" {
+			r.Header.Set("Range", tt.rangeHeader)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.wantStatus {
+			t.Errorf("Range %q: status = %d, want %d", tt.rangeHeader, w.Code, tt.wantStatus)
+			continue
+		}
+		if got := w.Header().Get("Content-Range"); got != tt.contentRange {
+			t.Errorf("Range %q: Content-Range = %q, want %q", tt.rangeHeader, got, tt.contentRange)
+		}
+		if tt.wantStatus != http.StatusRequestedRangeNotSatisfiable && !bytes.Equal(w.Body.Bytes(), data[tt.start:tt.end]) {
+			t.Errorf("Range %q: body has %d bytes, want data[%d:%d]", tt.rangeHeader, w.Body.Len(), tt.start, tt.end)
+		}
+		if tt.wantStatus != http.StatusRequestedRangeNotSatisfiable && w.Header().Get("Accept-Ranges") != "bytes" {
+			t.Errorf("Range %q: Accept-Ranges = %q", tt.rangeHeader, w.Header().Get("Accept-Ranges"))
+		}
+	}
+}
+
+func TestRangeFileHandlerMultiRange(t *testing.T) {
+	fsys, data := newRangeTestFS(t)
+	h := rangeFileHandler(fsys, http.NotFoundHandler())
+
+	r := httptest.NewRequest(http.MethodGet, "/video.mp4", nil)
+	r.Header.Set("Range", "bytes=0-9,500-,-5")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206", w.Code)
+	}
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("Content-Type = %q", w.Header().Get("Content-Type"))
+	}
+
+	want := []struct {
+		contentRange string
+		start, end   int
+	}{
+		{"bytes 0-9/1000", 0, 10},
+		{"bytes 500-999/1000", 500, 1000},
+		{"bytes 995-999/1000", 995, 1000},
+	}
+	mr := multipart.NewReader(w.Body, params["boundary"])
+	for i, part := range want {
+		p, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		if got := p.Header.Get("Content-Range"); got != part.contentRange {
+			t.Errorf("part %d: Content-Range = %q, want %q", i, got, part.contentRange)
+		}
+		body, _ := io.ReadAll(p)
+		if !bytes.Equal(body, data[part.start:part.end]) {
+			t.Errorf("part %d: body has %d bytes, want data[%d:%d]", i, len(body), part.start, part.end)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("多余的part: %v", err)
+	}
+}
+
+// Range响应经过完整的处理链时不能被压缩，否则Content-Range与响应体不符
+func TestFileHandlerRangeNotCompressed(t *testing.T) {
+	fsys, data := newRangeTestFS(t)
+	h := newFileHandler(fsys, fileServerOptions{
+		indexes:       []string{"index.html"},
+		cachePolicies: defaultCachePolicies(),
+		compress:      true,
+	})
+	r := httptest.NewRequest(http.MethodGet, "/video.mp4", nil)
+	r.Header.Set("Range", "bytes=100-199")
+	r.Header.Set("Accept-Encoding", "gzip, br")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusPartialContent || w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("status = %d, Content-Encoding = %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	if !bytes.Equal(w.Body.Bytes(), data[100:200]) {
+		t.Errorf("body has %d bytes, want data[100:200]", w.Body.Len())
+	}
+}
+
+// 只有本地化首页的目录在存在404页面时也应返回首页，而不是被noListingFS当作不存在
+func TestFileHandlerLocalizedIndexOnly(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"index.en.html":     "en",
+		"index.zh.html":     "zh",
+		"404.html":          "nf",
+		"empty/readme.txt":  "readme",
+		"docs/index.fr.htm": "not an index",
+	}
+	for name, body := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	langs, err := newLanguageNegotiator("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newFileHandler(http.Dir(root), fileServerOptions{
+		indexes:       []string{"index.html"},
+		notFoundPage:  "404.html",
+		cachePolicies: defaultCachePolicies(),
+		i18n:          langs,
+	})
+
+	tests := []struct {
+		path           string
+		acceptLanguage string
+		wantStatus     int
+		wantBody       string
+		wantLang       string
+	}{
+		{"/", "en", http.StatusOK, "en", "en"},
+		{"/", "zh-CN, en;q=0.5", http.StatusOK, "zh", "zh"},
+		{"/", "fr", http.StatusOK, "en", "en"},
+		{"/", "", http.StatusOK, "en", "en"},
+		{"/empty/", "en", http.StatusNotFound, "nf", ""},
+		{"/docs/", "fr", http.StatusNotFound, "nf", ""},
+		{"/missing.html", "en", http.StatusNotFound, "nf", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.acceptLanguage != "" {
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.wantStatus || w.Body.String() != tt.wantBody {
+			t.Errorf("%s (%q): got %d %q, want %d %q", tt.path, tt.acceptLanguage, w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+		}
+		if got := w.Header().Get("Content-Language"); got != tt.wantLang {
+			t.Errorf("%s (%q): Content-Language = %q, want %q", tt.path, tt.acceptLanguage, got, tt.wantLang)
+		}
+	}
+}
+
+// 目录请求返回首页时应带与直接请求首页相同的ETag和Digest，并能据此返回304
+func TestFileHandlerDirectoryValidators(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "index.html"), []byte("<h1>home</h1>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, etagContent := range []bool{false, true} {
+		fsys := http.Dir(root)
+		h := newFileHandler(fsys, fileServerOptions{
+			indexes:       []string{"index.html"},
+			cachePolicies: defaultCachePolicies(),
+			etagContent:   etagContent,
+			checksums:     newChecksumCache(fsys),
+		})
+		get := func(target, ifNoneMatch string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			if ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			return w
+		}
+
+		file, dir := get("/index.html", ""), get("/", "")
+		if dir.Code != http.StatusOK || dir.Body.String() != "<h1>home</h1>" {
+			t.Fatalf("etagContent=%v: GET / = %d %q", etagContent, dir.Code, dir.Body.String())
+		}
+		etag := dir.Header().Get("ETag")
+		if etag == "" || etag != file.Header().Get("ETag") {
+			t.Errorf("etagContent=%v: ETag of / = %q, /index.html = %q", etagContent, etag, file.Header().Get("ETag"))
+		}
+		if digest := dir.Header().Get("Digest"); digest == "" || digest != file.Header().Get("Digest") {
+			t.Errorf("etagContent=%v: Digest of / = %q, /index.html = %q", etagContent, digest, file.Header().Get("Digest"))
+		}
+		if w := get("/", etag); w.Code != http.StatusNotModified {
+			t.Errorf("etagContent=%v: If-None-Match on / = %d, want 304", etagContent, w.Code)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "strings"
+
+// multiFlag 可重复指定的字符串参数，如 -cache=.js=31536000 -cache=.html=no-cache
+type multiFlag []string
+
+func (f *multiFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *multiFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
@@ -1,3 +1,14 @@
 module http-server
 
 go 1.21.3
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/fsnotify/fsnotify v1.9.0
+	golang.org/x/net v0.35.0
+)
+
+require (
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+)
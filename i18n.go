@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// languageNegotiator 按Accept-Language为目录请求选择本地化的首页文件，
+// 如 index.html 对应 index.zh.html、index.zh-tw.html(文件名中的语言标签使用小写)
+type languageNegotiator struct {
+	defaultLang string // 没有匹配的语言时使用，为空则直接回退到不带语言的首页
+}
+
+// newLanguageNegotiator 校验默认语言并创建languageNegotiator
+func newLanguageNegotiator(defaultLang string) (*languageNegotiator, error) {
+	if defaultLang == "" {
+		return &languageNegotiator{}, nil
+	}
+	lang, ok := normalizeLanguageTag(defaultLang)
+	if !ok {
+		return nil, fmt.Errorf("无效的默认语言 %q", defaultLang)
+	}
+	return &languageNegotiator{defaultLang: lang}, nil
+}
+
+// normalizeLanguageTag 校验BCP 47语言标签并转为小写。标签会拼进文件名，
+// 只接受由-分隔、每段1到8个字母或数字的标签，不合法时返回false
+func normalizeLanguageTag(tag string) (string, bool) {
+	if tag == "" {
+		return "", false
+	}
+	for _, sub := range strings.Split(tag, "-") {
+		if len(sub) < 1 || len(sub) > 8 {
+			return "", false
+		}
+		for _, c := range sub {
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+				return "", false
+			}
+		}
+	}
+	return strings.ToLower(tag), true
+}
+
+// parseAcceptLanguage 按q值从高到低返回Accept-Language中小写的语言标签，
+// q值相同时保持原有顺序，q=0、*和不合法的标签被忽略
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag, ok := normalizeLanguageTag(strings.TrimSpace(tag))
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if s, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				continue
+			}
+			q = v
+		}
+		if q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
+
+// candidates 返回按优先级排列、需要尝试的小写语言标签。zh-CN之后会尝试zh，最后是默认语言
+func (n *languageNegotiator) candidates(r *http.Request) []string {
+	var langs []string
+	seen := make(map[string]bool)
+	add := func(lang string) {
+		if lang != "" && !seen[lang] {
+			seen[lang] = true
+			langs = append(langs, lang)
+		}
+	}
+	for _, tag := range parseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		add(tag)
+		if primary, _, ok := strings.Cut(tag, "-"); ok {
+			add(primary)
+		}
+	}
+	add(n.defaultLang)
+	return langs
+}
+
+// findLocalizedIndex 依次按langs查找目录dir下的index.<lang>.html等本地化首页，
+// 返回找到的文件及其语言，调用方负责关闭返回的文件
+func findLocalizedIndex(fsys http.FileSystem, dir string, indexes, langs []string) (http.File, os.FileInfo, string, bool) {
+	for _, lang := range langs {
+		names := make([]string, len(indexes))
+		for i, index := range indexes {
+			ext := path.Ext(index)
+			names[i] = strings.TrimSuffix(index, ext) + "." + lang + ext
+		}
+		if f, info, ok := findIndex(fsys, dir, names); ok {
+			return f, info, lang, true
+		}
+	}
+	return nil, nil, "", false
+}
+
+// findAnyLocalizedIndex 在没有匹配客户端语言的首页时，按indexes顺序返回目录dir下
+// 任一本地化首页(同一首页的多个语言按文件名排序取第一个)，调用方负责关闭返回的文件
+func findAnyLocalizedIndex(fsys http.FileSystem, dir string, indexes []string) (http.File, os.FileInfo, string, bool) {
+	d, err := fsys.Open(dir)
+	if err != nil {
+		return nil, nil, "", false
+	}
+	entries, err := d.Readdir(-1)
+	d.Close()
+	if err != nil {
+		return nil, nil, "", false
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	slices.Sort(names)
+
+	for _, index := range indexes {
+		ext := path.Ext(index)
+		prefix := strings.TrimSuffix(index, ext) + "."
+		for _, name := range names {
+			lang, ok := strings.CutPrefix(name, prefix)
+			if !ok {
+				continue
+			}
+			if lang, ok = strings.CutSuffix(lang, ext); !ok {
+				continue
+			}
+			if tag, ok := normalizeLanguageTag(lang); !ok || tag != lang {
+				continue
+			}
+			if f, info, ok := findIndex(fsys, dir, []string{name}); ok {
+				return f, info, lang, true
+			}
+		}
+	}
+	return nil, nil, "", false
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"zh-CN,zh;q=0.9,en;q=0.8", []string{"zh-cn", "zh", "en"}},
+		{"en;q=0.1, fr, zh;q=0.5", []string{"fr", "zh", "en"}},
+		{"de;q=0.5, fr;q=0.5", []string{"de", "fr"}},
+		{"fr, zh;q=0, *;q=0.3", []string{"fr"}},
+		{"en;q=abc, de", []string{"de"}},
+		{"x/../admin/secret, en", []string{"en"}},
+		{"../x, a.b, zh_CN, toolongsubtag, en-", []string{}},
+	}
+	for _, tt := range tests {
+		got := parseAcceptLanguage(tt.header)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAcceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageCandidates(t *testing.T) {
+	tests := []struct {
+		header      string
+		defaultLang string
+		want        []string
+	}{
+		{"zh-CN,en;q=0.5", "en", []string{"zh-cn", "zh", "en"}},
+		{"", "en", []string{"en"}},
+		{"", "", nil},
+		{"x/../admin/secret", "", nil},
+	}
+	for _, tt := range tests {
+		n, err := newLanguageNegotiator(tt.defaultLang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Language", tt.header)
+		if got := n.candidates(r); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("candidates(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestNewLanguageNegotiatorRejectsInvalidDefault(t *testing.T) {
+	for _, lang := range []string{"../en", "en/us", "e.n"} {
+		if _, err := newLanguageNegotiator(lang); err == nil {
+			t.Errorf("newLanguageNegotiator(%q) 应返回错误", lang)
+		}
+	}
+}
+
+// Accept-Language中的路径片段不能让目录请求读到目录外的文件
+func TestDirIndexHandlerLanguageTraversal(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"index.html":        "plain",
+		"index.zh.html":     "zh",
+		"admin/secret.html": "secret",
+	}
+	for name, body := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsys := http.Dir(root)
+	h := dirIndexHandler(fsys, []string{"index.html"}, &languageNegotiator{}, nil, http.NotFoundHandler())
+
+	tests := []struct {
+		acceptLanguage string
+		wantBody       string
+		wantLang       string
+	}{
+		{"x/../admin/secret", "plain", ""},
+		{"x/../admin/secret, zh", "zh", "zh"},
+		{"ZH-CN", "zh", "zh"},
+		{"fr", "plain", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Language", tt.acceptLanguage)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.String() != tt.wantBody {
+			t.Errorf("Accept-Language %q: got %d %q, want 200 %q", tt.acceptLanguage, w.Code, w.Body.String(), tt.wantBody)
+		}
+		if got := w.Header().Get("Content-Language"); got != tt.wantLang {
+			t.Errorf("Accept-Language %q: Content-Language = %q, want %q", tt.acceptLanguage, got, tt.wantLang)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseCIDRs(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"10.0.0.0/8, 192.168.1.0/24", []string{"10.0.0.0/8", "192.168.1.0/24"}, false},
+		{"192.0.2.7", []string{"192.0.2.7/32"}, false},
+		{"2001:db8::1", []string{"2001:db8::1/128"}, false},
+		{"10.0.0.1/8", []string{"10.0.0.0/8"}, false},
+		{"10.0.0.0/33", nil, true},
+		{"not-an-ip", nil, true},
+	}
+	for _, tt := range tests {
+		nets, err := parseCIDRs(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCIDRs(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		var got []string
+		for _, n := range nets {
+			got = append(got, n.String())
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseCIDRs(%q) = %v, want %v", tt.in, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseCIDRs(%q) = %v, want %v", tt.in, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestIPFilterMiddleware(t *testing.T) {
+	allow := mustCIDRs(t, "10.0.0.0/8")
+	deny := mustCIDRs(t, "10.6.6.6")
+	trust := proxyTrust{enabled: true, proxies: mustCIDRs(t, "172.16.0.1")}
+	h := ipFilterMiddleware(allow, deny, trust, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		want   int
+	}{
+		{"直连白名单地址", "10.1.2.3:1000", "", http.StatusOK},
+		{"直连非白名单地址", "192.0.2.1:1000", "", http.StatusForbidden},
+		{"黑名单优先", "10.6.6.6:1000", "", http.StatusForbidden},
+		{"经可信代理的白名单地址", "172.16.0.1:1000", "10.1.2.3", http.StatusOK},
+		{"伪造左侧的白名单地址", "172.16.0.1:1000", "10.0.0.1, 192.0.2.1", http.StatusForbidden},
+		{"不可信来源伪造XFF", "192.0.2.1:1000", "10.0.0.1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.xff != "" {
+			r.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+func TestContainsIP(t *testing.T) {
+	nets := mustCIDRs(t, "10.0.0.0/8,2001:db8::/32")
+	for ip, want := range map[string]bool{"10.255.0.1": true, "11.0.0.1": false, "2001:db8::5": true, "::1": false} {
+		if got := containsIP(nets, net.ParseIP(ip)); got != want {
+			t.Errorf("containsIP(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// listen 根据地址创建监听器。地址以unix:开头时监听Unix域套接字，
+// 返回的cleanup用于关闭后删除套接字文件；否则按TCP监听
+func listen(addr string, socketMode os.FileMode) (ln net.Listener, cleanup func(), err error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		ln, err = net.Listen("tcp", addr)
+		return ln, func() {}, err
+	}
+
+	// 删除上次异常退出残留的套接字文件，不是套接字的文件则不动，避免误删
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, nil, fmt.Errorf("%s 已存在且不是套接字文件", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, nil, fmt.Errorf("删除残留的套接字文件 %s 失败: %w", path, err)
+		}
+	}
+
+	ln, err = net.Listen("unix", path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		ln.Close()
+		return nil, nil, fmt.Errorf("设置套接字文件 %s 权限失败: %w", path, err)
+	}
+	return ln, func() { os.Remove(path) }, nil
+}
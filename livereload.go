@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 浏览器订阅重新加载事件的SSE地址
+const liveReloadPath = "/livereload"
+
+// 合并连续文件变更事件的等待时间，一次保存只触发一次刷新
+const liveReloadDebounce = 100 * time.Millisecond
+
+// 注入到HTML页面</body>之前的脚本，%q处填入SSE的完整地址
+const liveReloadScript = `<script>(function(){var es=new EventSource(%q);es.onmessage=function(e){if(e.data==="reload")location.reload();};})();</script>`
+
+// liveReloader 监听静态目录的变化，并通过SSE通知所有已连接的浏览器刷新
+type liveReloader struct {
+	watcher *fsnotify.Watcher
+	roots   []string // 监听的根目录，只在启动和切换目录时修改
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+	// 服务关闭时关闭，让SSE长连接及时结束，不拖慢优雅关闭
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newLiveReloader 递归监听dirs下的所有目录，文件变化时广播刷新事件
+func newLiveReloader(dirs []string) (*liveReloader, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if err := watchTree(watcher, dir); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	roots := make([]string, len(dirs))
+	for i, dir := range dirs {
+		roots[i] = filepath.Clean(dir)
+	}
+	lr := &liveReloader{watcher: watcher, roots: roots, clients: make(map[chan struct{}]struct{}), done: make(chan struct{})}
+	go lr.run(watcher)
+	return lr, nil
+}
+
+// watchTree 把root及其所有子目录加入监听，fsnotify本身不会递归
+func watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if err := watcher.Add(path); err != nil {
+				return fmt.Errorf("监听目录 %s 失败: %w", path, err)
+			}
+		}
+		return nil
+	})
+}
+
+// replaceDir 停止监听oldDir及其子目录，改为监听newDir，用于SIGHUP切换静态目录。
+// 仍属于其他根目录(如-mount的目录)的子目录继续监听
+func (lr *liveReloader) replaceDir(oldDir, newDir string) error {
+	if err := watchTree(lr.watcher, newDir); err != nil {
+		return err
+	}
+	oldDir, newDir = filepath.Clean(oldDir), filepath.Clean(newDir)
+	for i, root := range lr.roots {
+		if root == oldDir {
+			lr.roots[i] = newDir
+		}
+	}
+	for _, p := range lr.watcher.WatchList() {
+		if inDir(p, oldDir) && !slices.ContainsFunc(lr.roots, func(root string) bool { return inDir(p, root) }) {
+			lr.watcher.Remove(p)
+		}
+	}
+	log.Printf("livereload: 监听目录由 %s 切换为 %s", oldDir, newDir)
+	return nil
+}
+
+// inDir 判断p是否为dir或其子路径
+func inDir(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+func (lr *liveReloader) run(watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+	var debounce <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			// 新建的子目录也需要加入监听
+			if ev.Has(fsnotify.Create) {
+				if err := watchTree(watcher, ev.Name); err != nil {
+					log.Printf("livereload: %v", err)
+				}
+			}
+			debounce = time.After(liveReloadDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("livereload: 监听文件变化出错: %v", err)
+		case <-debounce:
+			debounce = nil
+			lr.broadcast()
+		}
+	}
+}
+
+func (lr *liveReloader) broadcast() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for ch := range lr.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	log.Printf("livereload: 检测到文件变化，通知 %d 个页面刷新", len(lr.clients))
+}
+
+// Close 断开所有SSE连接，注册为http.Server的RegisterOnShutdown回调
+func (lr *liveReloader) Close() {
+	lr.closeOnce.Do(func() { close(lr.done) })
+}
+
+// ServeHTTP 以SSE推送刷新事件，客户端断开后立即退出
+func (lr *liveReloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// SSE是长连接，不受服务端WriteTimeout限制
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	ch := make(chan struct{}, 1)
+	lr.mu.Lock()
+	lr.clients[ch] = struct{}{}
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, ch)
+		lr.mu.Unlock()
+	}()
+
+	flusher, _ := w.(http.Flusher)
+	fmt.Fprint(w, "retry: 1000\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-lr.done:
+			return
+		case <-ch:
+			fmt.Fprint(w, "data: reload\n\n")
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// liveReloadInjector 在HTML响应的</body>前注入刷新脚本，脚本订阅eventsURL
+func liveReloadInjector(eventsURL string, next http.Handler) http.Handler {
+	script := []byte(fmt.Sprintf(liveReloadScript, eventsURL))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// HEAD等请求没有响应体，不需要注入
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		iw := &injectWriter{ResponseWriter: w, status: http.StatusOK, script: script}
+		next.ServeHTTP(iw, r)
+		iw.finish()
+	})
+}
+
+// injectWriter 缓冲完整的HTML响应以便注入脚本，其他响应直接透传
+type injectWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buffering   bool
+	buf         bytes.Buffer
+	script      []byte
+}
+
+func (w *injectWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	// 已压缩的内容(如预压缩文件)无法直接注入
+	w.buffering = code == http.StatusOK && strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") &&
+		w.Header().Get("Content-Encoding") == ""
+	if !w.buffering {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *injectWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffering {
+		return w.buf.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *injectWriter) finish() {
+	if !w.buffering {
+		return
+	}
+	body := w.buf.Bytes()
+	script := w.script
+	if i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>")); i >= 0 {
+		body = append(body[:i:i], append(script, body[i:]...)...)
+	} else {
+		body = append(body, script...)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// jsonLog 不为nil时所有日志以JSON行格式输出
+var jsonLog *jsonLogWriter
+
+// setupLogging 根据-log-format配置标准库log的输出格式
+func setupLogging(format string) error {
+	switch format {
+	case "text":
+		return nil
+	case "json":
+		jsonLog = &jsonLogWriter{out: os.Stderr}
+		log.SetFlags(0)
+		log.SetOutput(jsonLog)
+		return nil
+	default:
+		return fmt.Errorf("无效的 -log-format %q，可选 text 或 json", format)
+	}
+}
+
+// jsonLogWriter 把log.Printf输出的每一行包装成 {"ts":...,"msg":...}
+type jsonLogWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+type jsonLogLine struct {
+	TS  string `json:"ts"`
+	Msg string `json:"msg"`
+}
+
+func (w *jsonLogWriter) Write(p []byte) (int, error) {
+	if err := w.writeEntry(jsonLogLine{
+		TS:  time.Now().Format(time.RFC3339Nano),
+		Msg: string(bytes.TrimRight(p, "\n")),
+	}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeEntry 把任意结构编码为一行JSON写出
+func (w *jsonLogWriter) writeEntry(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.out.Write(append(line, '\n'))
+	return err
+}
+
+// 每个请求的访问日志，text和json两种格式使用同一份数据
+type requestLogEntry struct {
+	TS         string  `json:"ts"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMS float64 `json:"duration_ms"`
+	Remote     string  `json:"remote"`
+	RequestID  string  `json:"request_id,omitempty"`
+}
+
+// logRequest 按当前日志格式输出一条访问日志
+func logRequest(e requestLogEntry) {
+	if jsonLog != nil {
+		jsonLog.writeEntry(e)
+		return
+	}
+	log.Printf("%s %s %d %dB %.3fms %s %s", e.Method, e.Path, e.Status, e.Bytes, e.DurationMS, e.Remote, e.RequestID)
+}
@@ -1,48 +1,584 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // 定义一个结构体来表示将要返回的JSON数据
 type JsonResponse struct {
 	Message string `json:"message"`
+	Time    string `json:"time,omitempty"`
 }
 
 func main() {
+	startTime = time.Now()
+
+	// 解析命令行参数，监听地址和静态资源目录均可配置
+	addr := flag.String("addr", ":8088", "监听地址，unix:/path/app.sock 表示监听Unix域套接字")
+	socketMode := flag.Uint("socket-mode", 0o660, "Unix域套接字文件的权限")
+	dir := flag.String("dir", "./public", "静态资源目录")
+	useEmbed := flag.Bool("embed", false, "使用编译时内嵌的public目录，忽略-dir")
+	listing := flag.Bool("listing", false, "目录下没有首页文件时是否自动生成目录列表")
+	notFoundPage := flag.String("404-page", "404.html", "文件不存在时返回的自定义页面(相对静态目录)，为空则使用默认404")
+	spa := flag.Bool("spa", false, "单页应用模式，不存在的前端路由返回index.html")
+	corsOrigin := flag.String("cors-origin", "*", "API允许跨域访问的来源，多个用逗号分隔，*表示任意来源")
+	etagContent := flag.Bool("etag-content", false, "按文件内容的SHA-256生成ETag，默认使用文件大小和修改时间")
+	certFile := flag.String("cert", "", "TLS证书文件路径，与-key同时指定时启用HTTPS")
+	keyFile := flag.String("key", "", "TLS私钥文件路径")
+	redirectHTTP := flag.Bool("redirect-http", false, "启用HTTPS时在80端口监听并把HTTP请求301跳转到HTTPS")
+	authUser := flag.String("auth-user", "", "Basic认证用户名")
+	authPass := flag.String("auth-pass", "", "Basic认证密码")
+	authPrefix := flag.String("auth-prefix", "", "需要Basic认证的路径前缀，为空则不启用")
+	rate := flag.Float64("rate", 0, "每个客户端IP每秒允许的API请求数，0表示不限流")
+	burst := flag.Int("burst", 10, "限流令牌桶容量，允许的突发请求数")
+	trustProxy := flag.Bool("trust-proxy", false, "部署在反向代理之后时，使用X-Forwarded-For识别客户端IP(限流和IP访问控制)")
+	trustedProxiesFlag := flag.String("trusted-proxies", "", "可信反向代理的网段，逗号分隔的CIDR，为空表示只信任直接相连的一跳代理")
+	greeting := flag.String("greeting", "Hello", "/api/getjson返回消息的问候语")
+	maxUpload := flag.Int64("max-upload", 32<<20, "/api/upload允许的最大请求体字节数")
+	readonly := flag.Bool("readonly", false, "只读模式，拒绝/api/upload上传")
+	readTimeout := flag.Duration("read-timeout", 15*time.Second, "读取整个请求(含请求体)的超时时间，0表示不限制")
+	readHeaderTimeout := flag.Duration("read-header-timeout", 5*time.Second, "读取请求头的超时时间，防御慢速请求头攻击")
+	writeTimeout := flag.Duration("write-timeout", 15*time.Second, "写出响应的超时时间，下载大文件时可适当调大，0表示不限制")
+	idleTimeout := flag.Duration("idle-timeout", 60*time.Second, "keep-alive连接的空闲超时时间")
+	proxyTarget := flag.String("proxy", "", "把/api/请求转发到的后端地址，如 http://127.0.0.1:3000")
+	nosniff := flag.Bool("nosniff", true, "设置 X-Content-Type-Options: nosniff")
+	frameOptions := flag.String("frame-options", "DENY", "X-Frame-Options的值，为空则不设置")
+	referrerPolicy := flag.String("referrer-policy", "no-referrer", "Referrer-Policy的值，为空则不设置")
+	csp := flag.String("csp", "", "Content-Security-Policy的值，为空则不设置")
+	echoMaxBody := flag.Int64("echo-max-body", 64<<10, "/api/echo最多回显的请求体字节数")
+	var cacheSpecs multiFlag
+	flag.Var(&cacheSpecs, "cache", "按扩展名覆盖Cache-Control，如 -cache=.js=31536000 或 -cache=.html=no-cache，可重复指定")
+	var mountSpecs multiFlag
+	flag.Var(&mountSpecs, "mount", "把目录挂载到URL前缀下，如 -mount=/docs=./docs，可重复指定")
+	logFormat := flag.String("log-format", "text", "日志格式，text 或 json")
+	var mimeSpecs multiFlag
+	flag.Var(&mimeSpecs, "mime", "强制指定Content-Type，如 -mime=.wasm=application/wasm 或 -mime=package=application/gzip，可重复指定")
+	enableH2C := flag.Bool("h2c", false, "启用明文HTTP/2(h2c)，便于本地测试HTTP/2客户端")
+	allowCIDRs := flag.String("allow", "", "允许访问的客户端网段，逗号分隔的CIDR，为空表示允许所有")
+	denyCIDRs := flag.String("deny", "", "拒绝访问的客户端网段，逗号分隔的CIDR，优先于-allow")
+	watch := flag.Bool("watch", false, "监听静态目录变化，自动刷新已打开的HTML页面")
+	enableMetrics := flag.Bool("metrics", false, "在/metrics暴露Prometheus格式的请求指标")
+	configFile := flag.String("config", "", "JSON配置文件路径，配置项与命令行参数同名，命令行参数优先")
+	enableCompress := flag.Bool("compress", true, "按Accept-Encoding对响应进行br/gzip压缩")
+	precompressed := flag.Bool("precompressed", false, "客户端支持时优先返回同名的.br/.gz预压缩文件")
+	genCert := flag.Bool("gen-cert", false, "为localhost生成自签名证书并退出，写入-cert和-key指定的路径(默认cert.pem/key.pem)")
+	index := flag.String("index", "index.html", "请求目录时依次尝试的首页文件，逗号分隔")
+	var mountIndexSpecs multiFlag
+	flag.Var(&mountIndexSpecs, "mount-index", "为挂载点单独设置首页文件，如 -mount-index=/docs=default.html,home.html，可重复指定")
+	maxResponse := flag.Int64("max-response", 10<<20, "本地/api/接口单个响应体的最大字节数，0表示不限制")
+	check := flag.Bool("check", false, "只校验配置(目录、证书、挂载、CIDR、配置文件等)并输出有效配置，不启动服务")
+	basePathFlag := flag.String("base-path", "", "整个服务(静态资源和/api/)挂载的URL前缀，如 /myapp")
+	var fileSpecs multiFlag
+	flag.Var(&fileSpecs, "file", "把指定URL映射到单个文件，如 -file=/favicon.ico=./assets/icon.png，可重复指定")
+	delay := flag.Duration("delay", 0, "给每个响应人为增加的延迟，如 200ms，用于测试加载状态")
+	allowDelayParam := flag.Bool("allow-delay-param", false, "允许通过查询参数 ?_delay=1s 覆盖单个请求的延迟(最长1分钟)")
+	apiCacheTTL := flag.Duration("api-cache-ttl", 0, "缓存/api/get和/api/getjson响应的时长，如 30s，0表示不缓存")
+	maintenanceFile := flag.String("maintenance-file", "", "该文件存在时进入维护模式，所有请求(/api/health除外)返回503")
+	checksums := flag.String("checksums", "", "启动时计算静态目录下所有文件的SHA-256并写入该清单文件，同时提供/checksums.json和Digest响应头")
+	checksumsEndpoint := flag.Bool("checksums-endpoint", true, "启用-checksums时是否提供/checksums.json，设为false则清单只写入文件")
+	i18n := flag.Bool("i18n", false, "请求目录时按Accept-Language返回 index.<lang>.html 等本地化首页，没有匹配时回退到 index.html")
+	i18nDefault := flag.String("i18n-default", "", "启用-i18n时没有匹配的语言所使用的默认语言，如 en")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "优雅关闭时等待进行中请求的最长时间")
+	shutdownDelay := flag.Duration("shutdown-delay", 0, "收到关闭信号后先让/api/health返回503并继续服务的时长，便于负载均衡摘除实例，如 5s")
+	flag.Parse()
+
+	// 优先级: 命令行参数 > 环境变量 > 配置文件 > 默认值
+	sources := explicitFlags(flag.CommandLine)
+	if err := applyEnv(flag.CommandLine, sources); err != nil {
+		log.Fatal(err)
+	}
+	if *configFile != "" {
+		cfg, err := loadConfig(*configFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := cfg.apply(flag.CommandLine, sources); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := setupLogging(*logFormat); err != nil {
+		log.Fatal(err)
+	}
+
+	if *genCert {
+		if *certFile == "" {
+			*certFile = "cert.pem"
+		}
+		if *keyFile == "" {
+			*keyFile = "key.pem"
+		}
+		if err := generateSelfSignedCert(*certFile, *keyFile); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("已生成自签名证书 %s 和私钥 %s，使用 -cert %s -key %s 启动HTTPS", *certFile, *keyFile, *certFile, *keyFile)
+		return
+	}
+
+	// 启动时校验静态资源目录是否存在，配置错误时直接退出
+	mounts, err := parseMounts(mountSpecs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// -mount覆盖了根路径时，根目录以它为准，-dir不再使用
+	rootMounted := false
+	for _, m := range mounts {
+		if m.prefix == "/" {
+			*dir, *useEmbed, rootMounted = m.dir, false, true
+		}
+	}
+	fileRoutes, err := parseFileRoutes(fileSpecs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	basePath, err := normalizeBasePath(*basePathFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mountIndexes, err := parseMountIndexes(mountIndexSpecs, mounts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	indexes := splitList(*index)
+	if len(indexes) == 0 {
+		log.Fatal("-index 至少需要一个文件名")
+	}
+	if !*useEmbed && !rootMounted {
+		if err := checkDir(*dir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	cachePolicies := defaultCachePolicies()
+	if err := parseCacheSpecs(cachePolicies, cacheSpecs); err != nil {
+		log.Fatal(err)
+	}
+
+	contentTypes, err := parseMimeSpecs(mimeSpecs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	allowNets, err := parseCIDRs(*allowCIDRs)
+	if err != nil {
+		log.Fatalf("解析 -allow 失败: %v", err)
+	}
+	denyNets, err := parseCIDRs(*denyCIDRs)
+	if err != nil {
+		log.Fatalf("解析 -deny 失败: %v", err)
+	}
+
+	trustedProxies, err := parseCIDRs(*trustedProxiesFlag)
+	if err != nil {
+		log.Fatalf("解析 -trusted-proxies 失败: %v", err)
+	}
+	if len(trustedProxies) > 0 && !*trustProxy {
+		log.Fatal("-trusted-proxies 需要同时指定 -trust-proxy")
+	}
+	trust := proxyTrust{enabled: *trustProxy, proxies: trustedProxies}
+
+	if *authPrefix != "" && (*authUser == "" || *authPass == "") {
+		log.Fatal("-auth-prefix 需要同时指定 -auth-user 和 -auth-pass")
+	}
+
+	useTLS := *certFile != "" || *keyFile != ""
+	if useTLS {
+		if err := checkCertificate(*certFile, *keyFile); err != nil {
+			log.Fatal(err)
+		}
+	} else if *redirectHTTP {
+		log.Fatal("-redirect-http 需要同时指定 -cert 和 -key")
+	}
+	if useTLS && *enableH2C {
+		log.Fatal("-h2c 只用于明文监听，启用HTTPS时会自动协商HTTP/2")
+	}
+
+	live := newLiveConfig(*configFile, flag.CommandLine, sources)
+	live.corsOrigins.Store(splitList(*corsOrigin))
+	mux := http.NewServeMux()
+	// /api/下的路由单独注册，统一经过只作用于API的中间件
+	api := http.NewServeMux()
+	var apiHandler http.Handler = api
+	// 限制本地API处理函数的响应大小，转发给后端的响应不受此限制
+	if *maxResponse > 0 {
+		apiHandler = maxResponseBytes(*maxResponse, apiHandler)
+	}
+	// 设置-proxy时/api/请求全部转发给后端，静态资源仍由本服务提供
+	if *proxyTarget != "" {
+		proxy, err := newAPIProxy(*proxyTarget)
+		if err != nil {
+			log.Fatal(err)
+		}
+		apiHandler = proxy
+		log.Printf("/api/ 请求将转发到 %s", *proxyTarget)
+	}
+	// API处理函数panic时返回JSON格式的500，而不是直接断开连接
+	apiHandler = recoverMiddleware(apiHandler)
+	// 指定了配置文件时始终启用限流中间件，以便SIGHUP后可以调整rate
+	if *rate > 0 || *configFile != "" {
+		live.limiter = newRateLimiter(*rate, *burst)
+		apiHandler = rateLimitMiddleware(live.limiter, trust, apiHandler)
+	}
+	mux.Handle("/api/", corsMiddleware(live.origins, apiHandler))
+
+	// 只缓存内容稳定的接口，health、echo等每次结果都不同
+	cacheAPI := func(h http.Handler) http.Handler { return h }
+	if *apiCacheTTL > 0 {
+		cacheAPI = newAPICache(*apiCacheTTL, apiCacheMaxEntries).middleware
+		log.Printf("已启用API响应缓存，TTL %s，最多 %d 条", *apiCacheTTL, apiCacheMaxEntries)
+	}
+
 	// 为/api/get路由定义处理函数,返回字符响应
-	http.HandleFunc("/api/get", func(w http.ResponseWriter, r *http.Request) {
+	var get http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// 写入应答
 		io.WriteString(w, "yes")
 	})
+	api.Handle("/api/get", methodHandler(map[string]http.HandlerFunc{
+		http.MethodGet: cacheAPI(get).ServeHTTP,
+	}))
 	// 为/api/getjson路由定义处理函数，返回JSON响应
-	http.HandleFunc("/api/getjson", func(w http.ResponseWriter, r *http.Request) {
+	var getJSON http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// 设置响应的内容类型为application/json
 		w.Header().Set("Content-Type", "application/json")
 
-		// 实例化JsonResponse结构体
+		// 读取name查询参数(Query()已完成URL解码)，缺省时使用world
+		name := strings.TrimSpace(r.URL.Query().Get("name"))
+		if name == "" {
+			name = "world"
+		}
+
+		// 实例化JsonResponse结构体，附带服务端时间便于客户端确认数据是否新鲜
 		response := JsonResponse{
-			Message: "Hello, this is JSON response",
+			Message: *greeting + ", " + name,
+			Time:    time.Now().Format(time.RFC3339),
 		}
 
 		// 编码并写入JSON响应
 		json.NewEncoder(w).Encode(response)
 	})
+	// 缓存未压缩的响应，压缩按每个请求的Accept-Encoding单独协商
+	getJSON = cacheAPI(getJSON)
+	if *enableCompress {
+		getJSON = compressHandler(getJSON)
+	}
+	api.Handle("/api/getjson", methodHandler(map[string]http.HandlerFunc{
+		http.MethodGet: getJSON.ServeHTTP,
+	}))
+
+	// 接收并校验JSON请求体
+	api.Handle("/api/json", methodHandler(map[string]http.HandlerFunc{
+		http.MethodPost: jsonHandler,
+	}))
+
+	// 健康检查接口
+	api.Handle("/api/health", methodHandler(map[string]http.HandlerFunc{
+		http.MethodGet: healthHandler,
+	}))
+
+	// 上传接口，文件保存到静态目录下；内嵌资源不可写入，始终按只读处理
+	api.Handle("/api/upload", methodHandler(map[string]http.HandlerFunc{
+		http.MethodPost: uploadHandler(func() string { return live.currentSite().dir }, *maxUpload, *readonly || *useEmbed),
+	}))
+
+	// 调试用接口，原样返回收到的请求，不限制请求方法
+	api.HandleFunc("/api/echo", echoHandler(*echoMaxBody))
 
 	// 静态资源服务器，设置静态文件的目录
-	staticDir := http.Dir("./public")
-	// 使用FileServer处理静态文件请求
-	http.Handle("/", http.FileServer(staticDir))
+	fileOpts := fileServerOptions{
+		listing:       *listing,
+		indexes:       indexes,
+		notFoundPage:  *notFoundPage,
+		spa:           *spa,
+		etagContent:   *etagContent,
+		cachePolicies: cachePolicies,
+		contentTypes:  contentTypes,
+		compress:      *enableCompress,
+		precompressed: *precompressed,
+	}
+	if *i18n {
+		fileOpts.i18n, err = newLanguageNegotiator(*i18nDefault)
+		if err != nil {
+			log.Fatalf("解析 -i18n-default 失败: %v", err)
+		}
+	}
+	var lr *liveReloader
+	if *watch {
+		var watchDirs []string
+		if !*useEmbed {
+			watchDirs = append(watchDirs, *dir)
+		}
+		for _, m := range mounts {
+			if m.prefix != "/" {
+				watchDirs = append(watchDirs, m.dir)
+			}
+		}
+		lr, err = newLiveReloader(watchDirs)
+		if err != nil {
+			log.Fatalf("启动文件监听失败: %v", err)
+		}
+		mux.Handle(liveReloadPath, lr)
+		fileOpts.liveReloadURL = basePath + liveReloadPath
+		log.Printf("已启用livereload，监听目录 %v", watchDirs)
+	}
+	// 根目录的静态资源，-mount覆盖根路径时*dir已指向挂载目录
+	var staticDir http.FileSystem = http.Dir(*dir)
+	if *useEmbed {
+		embedded, err := embeddedPublic()
+		if err != nil {
+			log.Fatalf("加载内嵌静态资源失败: %v", err)
+		}
+		staticDir = embedded
+	}
+	rootOpts := fileOpts
+	if idx, ok := mountIndexes["/"]; ok {
+		rootOpts.indexes = idx
+	}
+	// buildSite 为根目录构建静态资源快照，启动和SIGHUP切换目录时使用
+	buildSite := func(dir string, fsys http.FileSystem) (*staticSite, error) {
+		site := &staticSite{dir: dir, fsys: fsys}
+		opts := rootOpts
+		if *checksums != "" {
+			site.sums = newChecksumCache(fsys)
+			if *authPrefix != "" {
+				site.sums.hidden = []string{*authPrefix}
+			}
+			opts.checksums = site.sums
+			// -check只做校验，不写入任何文件
+			if !*check {
+				n, err := site.sums.writeManifest(*checksums)
+				if err != nil {
+					return nil, fmt.Errorf("生成校验和清单失败: %w", err)
+				}
+				log.Printf("已计算 %d 个文件的SHA-256，清单写入 %s", n, *checksums)
+			}
+		}
+		site.handler = newFileHandler(fsys, opts)
+		return site, nil
+	}
+	site, err := buildSite(*dir, staticDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	live.site.Store(site)
+	live.reloader = lr
+	if !*useEmbed && !rootMounted {
+		live.newSite = func(dir string) (*staticSite, error) {
+			if err := checkDir(dir); err != nil {
+				return nil, err
+			}
+			return buildSite(dir, http.Dir(dir))
+		}
+	}
+	// 清单会列出目录下的所有文件(-auth-prefix下的除外)，不希望公开时用-checksums-endpoint=false关闭
+	if *checksums != "" && *checksumsEndpoint {
+		mux.HandleFunc("/checksums.json", live.serveChecksums)
+	} else if *checksums != "" {
+		log.Printf("未启用 -checksums-endpoint，不提供 /checksums.json，清单只写入 %s", *checksums)
+	}
+	for _, m := range mounts {
+		opts := fileOpts
+		if idx, ok := mountIndexes[m.prefix]; ok {
+			opts.indexes = idx
+		}
+		if m.prefix == "/" {
+			opts.checksums = site.sums
+		}
+		handleMount(mux, m.prefix, newFileHandler(http.Dir(m.dir), opts))
+		log.Printf("挂载 %s -> %s", m.prefix, m.dir)
+	}
+	// 精确路径的模式比/和挂载前缀更具体，ServeMux会优先匹配
+	for _, fr := range fileRoutes {
+		mux.Handle(fr.route, serveFileRoute(fr.file))
+		log.Printf("路由 %s -> 文件 %s", fr.route, fr.file)
+	}
+	var requestMetrics *metrics
+	if *enableMetrics {
+		requestMetrics = newMetrics(basePath, []string{"/metrics", liveReloadPath,
+			"/api/get", "/api/getjson", "/api/json", "/api/health", "/api/upload", "/api/echo", "/checksums.json"})
+		mux.Handle("/metrics", requestMetrics)
+	}
+	// 没有通过-mount覆盖根路径时，默认把-dir(或内嵌资源)挂载到/
+	if !rootMounted {
+		mux.Handle("/", live)
+	}
+
+	// 中间件由内向外包装，越靠后添加的越先执行
+	var handler http.Handler = mux
+	if *authPrefix != "" {
+		handler = basicAuthMiddleware(*authPrefix, *authUser, *authPass, handler)
+	}
+	sh := securityHeaders{
+		FrameOptions:          *frameOptions,
+		ReferrerPolicy:        *referrerPolicy,
+		ContentSecurityPolicy: *csp,
+	}
+	if *nosniff {
+		sh.ContentTypeOptions = "nosniff"
+	}
+	handler = securityHeadersMiddleware(sh, handler)
+	if len(allowNets) > 0 || len(denyNets) > 0 {
+		handler = ipFilterMiddleware(allowNets, denyNets, trust, handler)
+	}
+
+	// 维护模式放在前缀剥离之后，按去掉-base-path的路径放行健康检查
+	handler = maintenanceMiddleware(liveSiteFS{live}, handler)
+	if *maintenanceFile != "" {
+		watchMaintenanceFile(*maintenanceFile, time.Second)
+	}
 
-	// 设置监听端口
-	addr := ":8088"
-	log.Printf("服务端正在监听端口 %s，请在同目录下的public/目录里修改静态资源哦!", addr)
+	if basePath != "" {
+		handler = basePathHandler(basePath, handler)
+		log.Printf("服务挂载在路径前缀 %s/ 下", basePath)
+	}
+
+	if *delay > 0 || *allowDelayParam {
+		handler = delayMiddleware(*delay, *allowDelayParam, handler)
+		log.Printf("已启用延迟注入: 默认 %s，允许查询参数覆盖: %v", *delay, *allowDelayParam)
+	}
+
+	// 整个mux都经过日志中间件，API和静态文件请求都会被记录
+	handler = loggingMiddleware(requestMetrics, handler)
+	// 请求ID在最外层生成，日志和所有处理函数都能拿到
+	handler = requestIDMiddleware(handler)
+	handler = inFlightMiddleware(handler)
+	// h2c需要在最外层识别HTTP/2的连接前言(prior knowledge)或Upgrade请求
+	if *enableH2C {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+		log.Printf("已启用h2c(明文HTTP/2)")
+	}
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadTimeout:       *readTimeout,
+		ReadHeaderTimeout: *readHeaderTimeout,
+		WriteTimeout:      *writeTimeout,
+		IdleTimeout:       *idleTimeout,
+	}
+
+	if lr != nil {
+		srv.RegisterOnShutdown(lr.Close)
+	}
 
-	// 开始监听并提供服务
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	// -check模式下所有启动校验都已通过，输出有效配置后退出，不监听端口
+	if *check {
+		printEffectiveConfig(os.Stdout, flag.CommandLine, sources)
+		fmt.Println("配置校验通过")
+		return
+	}
+
+	if *useEmbed {
+		log.Printf("服务端正在监听端口 %s，使用内嵌的静态资源", *addr)
+	} else {
+		log.Printf("服务端正在监听端口 %s，请在 %s 目录里修改静态资源哦!", *addr, *dir)
+	}
+	log.Printf("超时设置: read=%s read-header=%s write=%s idle=%s", *readTimeout, *readHeaderTimeout, *writeTimeout, *idleTimeout)
+
+	ln, removeSocket, err := listen(*addr, os.FileMode(*socketMode))
+	if err != nil {
 		log.Fatal(err)
 	}
+	defer removeSocket()
+
+	// 在goroutine中开始提供服务
+	serveErr := make(chan error, 2)
+	go func() {
+		if useTLS {
+			serveErr <- srv.ServeTLS(ln, *certFile, *keyFile)
+		} else {
+			serveErr <- srv.Serve(ln)
+		}
+	}()
+
+	var redirectSrv *http.Server
+	if *redirectHTTP {
+		redirectSrv = &http.Server{
+			Addr:              redirectAddr,
+			Handler:           httpsRedirectHandler(*addr),
+			ReadHeaderTimeout: *readHeaderTimeout,
+			IdleTimeout:       *idleTimeout,
+		}
+		go func() {
+			if err := redirectSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- fmt.Errorf("HTTP跳转服务: %w", err)
+			}
+		}()
+		log.Printf("HTTP跳转服务正在监听端口 %s", redirectAddr)
+	}
+
+	if maintenanceSignal != nil {
+		toggle := make(chan os.Signal, 1)
+		signal.Notify(toggle, maintenanceSignal)
+		go func() {
+			for range toggle {
+				if toggleMaintenance() {
+					log.Printf("收到信号，进入维护模式")
+				} else {
+					log.Printf("收到信号，退出维护模式")
+				}
+			}
+		}()
+	}
+
+	// 收到SIGHUP时重新加载配置文件，失败时保留原有配置
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := live.reload(); err != nil {
+				log.Printf("重新加载配置失败，继续使用原有配置: %v", err)
+			}
+		}
+	}()
+
+	// 监听中断信号，收到后优雅关闭，让进行中的请求(如大文件下载)有机会完成
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-serveErr:
+		log.Fatal(err)
+	case sig := <-quit:
+		log.Printf("收到信号 %v, shutting down", sig)
+	}
+
+	shuttingDown.Store(true)
+	// 监听器在Shutdown时关闭，之前留出时间让负载均衡的健康检查看到503，再次收到信号时立即关闭
+	if *shutdownDelay > 0 {
+		log.Printf("/api/health 开始返回503，%v 后关闭服务", *shutdownDelay)
+		select {
+		case <-time.After(*shutdownDelay):
+		case sig := <-quit:
+			log.Printf("再次收到信号 %v，跳过等待", sig)
+		}
+	}
+	log.Printf("开始优雅关闭，当前有 %d 个请求处理中", inFlightRequests.Load())
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if redirectSrv != nil {
+		redirectSrv.Shutdown(ctx)
+	}
+	err = srv.Shutdown(ctx)
+	// Shutdown不会等待被接管(hijack)的连接，这里再按计数等待进行中的请求结束
+	if n := waitForDrain(ctx); n > 0 {
+		log.Printf("等待超时，仍有 %d 个请求未完成", n)
+	}
+	if serr := <-serveErr; !errors.Is(serr, http.ErrServerClosed) {
+		err = errors.Join(err, serr)
+	}
+	log.Printf("服务端已关闭: %v", err)
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// 维护模式的状态：信号手动切换和维护文件存在任一成立即进入维护模式
+var (
+	maintenanceManual atomic.Bool
+	maintenanceByFile atomic.Bool
+)
+
+// 维护期间返回的Retry-After秒数
+const maintenanceRetryAfter = "120"
+
+// 维护模式下使用的自定义页面，位于静态目录根下
+const maintenancePage = "maintenance.html"
+
+func inMaintenance() bool {
+	return maintenanceManual.Load() || maintenanceByFile.Load()
+}
+
+// toggleMaintenance 切换手动维护状态，返回切换后的状态
+func toggleMaintenance() bool {
+	for {
+		old := maintenanceManual.Load()
+		if maintenanceManual.CompareAndSwap(old, !old) {
+			return !old
+		}
+	}
+}
+
+// watchMaintenanceFile 定期检查path是否存在，存在时进入维护模式，删除后恢复
+func watchMaintenanceFile(path string, interval time.Duration) {
+	check := func() {
+		_, err := os.Stat(path)
+		on := err == nil
+		if maintenanceByFile.Swap(on) != on {
+			if on {
+				log.Printf("检测到维护文件 %s，进入维护模式", path)
+			} else {
+				log.Printf("维护文件 %s 已删除，退出维护模式", path)
+			}
+		}
+	}
+	check()
+	go func() {
+		for range time.Tick(interval) {
+			check()
+		}
+	}()
+}
+
+// maintenanceMiddleware 在维护模式下对除健康检查外的所有请求返回503。
+// 静态目录fsys根下存在maintenance.html时用它作为响应体
+func maintenanceMiddleware(fsys http.FileSystem, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !inMaintenance() || r.URL.Path == "/api/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Set("Retry-After", maintenanceRetryAfter)
+		h.Set("Cache-Control", "no-store")
+		if f, err := fsys.Open("/" + maintenancePage); err == nil {
+			defer f.Close()
+			h.Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			if r.Method != http.MethodHead {
+				io.Copy(w, f)
+			}
+			return
+		}
+		http.Error(w, "服务维护中，请稍后再试", http.StatusServiceUnavailable)
+	})
+}
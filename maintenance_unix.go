@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// 切换维护模式的信号
+var maintenanceSignal os.Signal = syscall.SIGUSR1
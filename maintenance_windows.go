@@ -0,0 +1,6 @@
+package main
+
+import "os"
+
+// Windows没有SIGUSR1，只能通过-maintenance-file切换维护模式
+var maintenanceSignal os.Signal
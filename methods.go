@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// methodHandler 按请求方法分发到对应的处理函数。
+// 声明了GET时自动支持HEAD；OPTIONS返回204和Allow头；其余方法返回405
+func methodHandler(handlers map[string]http.HandlerFunc) http.Handler {
+	if get, ok := handlers[http.MethodGet]; ok {
+		if _, ok := handlers[http.MethodHead]; !ok {
+			handlers[http.MethodHead] = get
+		}
+	}
+	methods := make([]string, 0, len(handlers)+1)
+	for m := range handlers {
+		methods = append(methods, m)
+	}
+	if _, ok := handlers[http.MethodOptions]; !ok {
+		methods = append(methods, http.MethodOptions)
+	}
+	sort.Strings(methods)
+	allow := strings.Join(methods, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, ok := handlers[r.Method]; ok {
+			h(w, r)
+			return
+		}
+		w.Header().Set("Allow", allow)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSONError(w, http.StatusMethodNotAllowed, "不支持的请求方法 "+r.Method+"，允许: "+allow)
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 请求耗时直方图的桶边界(秒)
+var durationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// 静态文件请求统一使用的path标签，避免每个文件产生一个时间序列
+const staticPathLabel = "static"
+
+type requestKey struct {
+	path   string
+	status int
+}
+
+type histogram struct {
+	counts []uint64 // 与durationBuckets一一对应，不含+Inf
+	count  uint64
+	sum    float64
+}
+
+// metrics 以Prometheus文本格式暴露的请求指标
+type metrics struct {
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[string]*histogram
+	// 被单独统计的路由，其他/api/路径归为/api/other，剩下的归为static
+	routes map[string]bool
+	// 统计前从路径中去掉的-base-path前缀
+	basePath string
+}
+
+func newMetrics(basePath string, routes []string) *metrics {
+	m := &metrics{
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[string]*histogram),
+		routes:    make(map[string]bool),
+		basePath:  basePath,
+	}
+	for _, route := range routes {
+		m.routes[route] = true
+	}
+	return m
+}
+
+// pathLabel 把请求路径归一化为有限的几个标签值，控制时间序列的数量
+func (m *metrics) pathLabel(path string) string {
+	if m.basePath != "" {
+		rest, ok := strings.CutPrefix(path, m.basePath)
+		if !ok {
+			return staticPathLabel
+		}
+		path = rest
+	}
+	if m.routes[path] {
+		return path
+	}
+	if strings.HasPrefix(path, "/api/") {
+		return "/api/other"
+	}
+	return staticPathLabel
+}
+
+// observe 记录一次已完成的请求
+func (m *metrics) observe(path string, status int, d time.Duration) {
+	label := m.pathLabel(path)
+	seconds := d.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{path: label, status: status}]++
+	h, ok := m.durations[label]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		m.durations[label] = h
+	}
+	for i, le := range durationBuckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// ServeHTTP 输出Prometheus文本格式(text/plain; version=0.0.4)
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP http_requests_total Total number of HTTP requests.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		return keys[i].status < keys[j].status
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "http_requests_total{path=%q,status=\"%d\"} %d\n", k.path, k.status, m.requests[k])
+	}
+
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds HTTP request latency in seconds.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	paths := make([]string, 0, len(m.durations))
+	for p := range m.durations {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		h := m.durations[p]
+		for i, le := range durationBuckets {
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{path=%q,le=%q} %d\n", p, strconv.FormatFloat(le, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{path=%q,le=\"+Inf\"} %d\n", p, h.count)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{path=%q} %g\n", p, h.sum)
+		fmt.Fprintf(w, "http_request_duration_seconds_count{path=%q} %d\n", p, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP http_requests_in_flight Number of HTTP requests currently being served.")
+	fmt.Fprintln(w, "# TYPE http_requests_in_flight gauge")
+	fmt.Fprintf(w, "http_requests_in_flight %d\n", inFlightRequests.Load())
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// statusRecorder 包装ResponseWriter，记录处理函数写出的状态码和字节数
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(p []byte) (int, error) {
+	// 处理函数未调用WriteHeader就写入时，net/http默认返回200
+	if !rec.wroteHeader {
+		rec.status = http.StatusOK
+		rec.wroteHeader = true
+	}
+	n, err := rec.ResponseWriter.Write(p)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 让http.ResponseController可以访问到底层的ResponseWriter
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// loggingMiddleware 为每个请求输出一行日志: 方法 路径 状态码 耗时 客户端地址，
+// m不为nil时同时更新Prometheus指标
+func loggingMiddleware(m *metrics, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if m != nil {
+			m.observe(r.URL.Path, rec.status, time.Since(start))
+		}
+		logRequest(requestLogEntry{
+			TS:         start.Format(time.RFC3339Nano),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     rec.status,
+			Bytes:      rec.bytes,
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			Remote:     remoteHost(r),
+			RequestID:  requestIDFromContext(r.Context()),
+		})
+	})
+}
+
+// remoteHost 从RemoteAddr中去掉端口，只保留客户端地址
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// inFlightMiddleware 统计正在处理中的请求数，供健康检查、指标和优雅关闭使用
+func inFlightMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlightRequests.Add(1)
+		defer inFlightRequests.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsMiddleware 为API添加跨域响应头，并直接以204应答预检请求。
+// 允许的来源每个请求通过origins()读取一次，以便热更新；
+// 其中包含"*"时允许任意来源，否则只回显列表中匹配的Origin
+func corsMiddleware(origins func() []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" {
+			allowedOrigins := origins()
+			h := w.Header()
+			addVary(h, "Origin")
+			if allowOrigin := matchOrigin(allowedOrigins, origin); allowOrigin != "" {
+				h.Set("Access-Control-Allow-Origin", allowOrigin)
+				h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				h.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			}
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// matchOrigin 返回应写入Access-Control-Allow-Origin的值，不允许时返回空串
+func matchOrigin(allowedOrigins []string, origin string) string {
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// splitList 解析逗号分隔的参数值，忽略空白项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// addVary 向Vary响应头追加field，已经存在时不再重复添加
+func addVary(h http.Header, field string) {
+	for _, v := range h.Values("Vary") {
+		for _, existing := range splitList(v) {
+			if strings.EqualFold(existing, field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
+
+// basicAuthMiddleware 对路径以prefix开头的请求校验Basic认证，其他路径不受影响。
+// 使用常量时间比较，避免通过响应耗时猜测凭据
+func basicAuthMiddleware(prefix, user, pass string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		u, p, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(p), []byte(pass)) == 1
+		if !ok || !userOK || !passOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// securityHeaders 需要附加到每个响应上的安全响应头，值为空的项不设置
+type securityHeaders struct {
+	ContentTypeOptions    string
+	FrameOptions          string
+	ReferrerPolicy        string
+	ContentSecurityPolicy string
+}
+
+// securityHeadersMiddleware 为所有响应设置常用的安全加固响应头
+func securityHeadersMiddleware(sh securityHeaders, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if sh.ContentTypeOptions != "" {
+			h.Set("X-Content-Type-Options", sh.ContentTypeOptions)
+		}
+		if sh.FrameOptions != "" {
+			h.Set("X-Frame-Options", sh.FrameOptions)
+		}
+		if sh.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", sh.ReferrerPolicy)
+		}
+		if sh.ContentSecurityPolicy != "" {
+			h.Set("Content-Security-Policy", sh.ContentSecurityPolicy)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recoverMiddleware 捕获处理函数中的panic，记录堆栈并返回JSON格式的500。
+// 如果响应已经开始写出，就无法再改状态码，此时只记录日志
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// ErrAbortHandler是net/http约定的中断信号，继续向上抛出
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			log.Printf("处理 %s %s 时发生panic: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+			if rec.wroteHeader {
+				return
+			}
+			writeJSONError(rec, http.StatusInternalServerError, "internal server error")
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// errResponseTooLarge 响应超过maxResponseBytes限制后继续写入时返回
+var errResponseTooLarge = errors.New("响应超过大小限制")
+
+// maxResponseBytes 限制处理函数写出的响应体大小，正常大小的响应不受影响。
+// 第一次写入就超限时还没有发出响应头，改为返回JSON格式的500；
+// 否则写到限制处为止，之后的数据丢弃并记录错误
+func maxResponseBytes(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lw := &limitedResponseWriter{ResponseWriter: w, limit: limit}
+		next.ServeHTTP(lw, r)
+		if lw.exceeded {
+			log.Printf("%s %s 的响应超过 %d 字节限制，已截断(已写出 %d 字节)", r.Method, r.URL.Path, limit, lw.written)
+		}
+	})
+}
+
+type limitedResponseWriter struct {
+	http.ResponseWriter
+	limit       int64
+	written     int64
+	wroteHeader bool
+	exceeded    bool
+}
+
+func (w *limitedResponseWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *limitedResponseWriter) Write(p []byte) (int, error) {
+	if w.exceeded {
+		return 0, errResponseTooLarge
+	}
+	if w.written+int64(len(p)) <= w.limit {
+		n, err := w.ResponseWriter.Write(p)
+		w.written += int64(n)
+		w.wroteHeader = true
+		return n, err
+	}
+
+	w.exceeded = true
+	if !w.wroteHeader && w.written == 0 {
+		w.Header().Del("Content-Length")
+		writeJSONError(w.ResponseWriter, http.StatusInternalServerError, "response too large")
+		w.wroteHeader = true
+		return 0, errResponseTooLarge
+	}
+	n, _ := w.ResponseWriter.Write(p[:w.limit-w.written])
+	w.written += int64(n)
+	return n, errResponseTooLarge
+}
+
+func (w *limitedResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
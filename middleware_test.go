@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		origin  string
+		want    string
+	}{
+		{[]string{"*"}, "http://a.com", "*"},
+		{[]string{"http://a.com"}, "http://a.com", "http://a.com"},
+		{[]string{"http://a.com"}, "HTTP://A.COM", "HTTP://A.COM"},
+		{[]string{"http://a.com", "http://b.com"}, "http://b.com", "http://b.com"},
+		{[]string{"http://a.com"}, "http://a.com.evil.com", ""},
+		{[]string{"http://a.com"}, "http://evil-a.com", ""},
+		{[]string{"http://a.com"}, "https://a.com", ""},
+		{[]string{"http://a.com"}, "http://a.com:8080", ""},
+		{nil, "http://a.com", ""},
+	}
+	for _, tt := range tests {
+		if got := matchOrigin(tt.allowed, tt.origin); got != tt.want {
+			t.Errorf("matchOrigin(%q, %q) = %q, want %q", tt.allowed, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	origins := func() []string { return []string{"http://a.com"} }
+	h := corsMiddleware(origins, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	r := httptest.NewRequest(http.MethodOptions, "/api/get", nil)
+	r.Header.Set("Origin", "http://a.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "http://a.com" {
+		t.Errorf("预检请求: status=%d allow-origin=%q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/get", nil)
+	r.Header.Set("Origin", "http://evil.com")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusTeapot || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("不允许的来源: status=%d allow-origin=%q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if w.Header().Get("Vary") != "Origin" {
+		t.Errorf("Vary = %q, want Origin", w.Header().Get("Vary"))
+	}
+}
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	h := basicAuthMiddleware("/admin", "user", "secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		name       string
+		path       string
+		user, pass string
+		setAuth    bool
+		want       int
+	}{
+		{"前缀之外不需要认证", "/index.html", "", "", false, http.StatusOK},
+		{"缺少认证", "/admin/a.txt", "", "", false, http.StatusUnauthorized},
+		{"密码错误", "/admin/a.txt", "user", "wrong", true, http.StatusUnauthorized},
+		{"用户名错误", "/admin/a.txt", "other", "secret", true, http.StatusUnauthorized},
+		{"密码为前缀", "/admin/a.txt", "user", "secre", true, http.StatusUnauthorized},
+		{"空凭据", "/admin", "", "", true, http.StatusUnauthorized},
+		{"认证通过", "/admin/a.txt", "user", "secret", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.setAuth {
+			r.SetBasicAuth(tt.user, tt.pass)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: 401响应缺少WWW-Authenticate", tt.name)
+		}
+	}
+}
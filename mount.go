@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// mount 一个URL前缀到磁盘目录的映射
+type mount struct {
+	prefix string
+	dir    string
+}
+
+// reservedPaths 服务自身注册的路由，-mount和-file不能与之冲突
+var reservedPaths = []string{"/api", "/metrics", liveReloadPath, "/checksums.json"}
+
+// reservedPath 返回与p冲突的内置路由
+func reservedPath(p string) (string, bool) {
+	for _, r := range reservedPaths {
+		if p == r || strings.HasPrefix(p, r+"/") {
+			return r, true
+		}
+	}
+	return "", false
+}
+
+// parseMounts 解析 -mount=/docs=./docs 形式的参数，并校验目录存在
+func parseMounts(specs []string) ([]mount, error) {
+	var mounts []mount
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		prefix, dir, ok := strings.Cut(spec, "=")
+		prefix, dir = strings.TrimSpace(prefix), strings.TrimSpace(dir)
+		if !ok || !strings.HasPrefix(prefix, "/") || dir == "" {
+			return nil, fmt.Errorf("无效的 -mount 参数 %q，格式应为 /前缀=目录", spec)
+		}
+		if prefix != "/" {
+			prefix = strings.TrimSuffix(prefix, "/")
+		}
+		if r, ok := reservedPath(prefix); ok {
+			return nil, fmt.Errorf("-mount 前缀 %s 与内置路由 %s 冲突", prefix, r)
+		}
+		if seen[prefix] {
+			return nil, fmt.Errorf("-mount 前缀 %s 重复", prefix)
+		}
+		seen[prefix] = true
+		if err := checkDir(dir); err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, mount{prefix: prefix, dir: dir})
+	}
+	return mounts, nil
+}
+
+// checkDir 校验静态资源目录存在且确实是目录
+func checkDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("静态资源目录 %s 不可用: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("静态资源路径 %s 不是一个目录", dir)
+	}
+	return nil
+}
+
+// handleMount 把静态文件处理器注册到prefix下，非根前缀会先去掉前缀再交给处理器
+func handleMount(mux *http.ServeMux, prefix string, h http.Handler) {
+	if prefix == "/" {
+		mux.Handle("/", h)
+		return
+	}
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, h))
+}
+
+// parseMountIndexes 解析 -mount-index=/docs=default.html,home.html 形式的参数，
+// 为指定挂载点单独设置首页文件列表
+func parseMountIndexes(specs []string, mounts []mount) (map[string][]string, error) {
+	known := map[string]bool{"/": true}
+	for _, m := range mounts {
+		known[m.prefix] = true
+	}
+
+	indexes := make(map[string][]string)
+	for _, spec := range specs {
+		prefix, list, ok := strings.Cut(spec, "=")
+		prefix = strings.TrimSpace(prefix)
+		if prefix != "/" {
+			prefix = strings.TrimSuffix(prefix, "/")
+		}
+		names := splitList(list)
+		if !ok || len(names) == 0 {
+			return nil, fmt.Errorf("无效的 -mount-index 参数 %q，格式应为 /前缀=文件1,文件2", spec)
+		}
+		if !known[prefix] {
+			return nil, fmt.Errorf("-mount-index 前缀 %s 没有对应的 -mount", prefix)
+		}
+		indexes[prefix] = names
+	}
+	return indexes, nil
+}
+
+// fileRoute 把一个精确的URL映射到单个文件
+type fileRoute struct {
+	route string
+	file  string
+}
+
+// parseFileRoutes 解析 -file=/favicon.ico=./assets/icon.png 形式的参数，
+// 校验文件存在且路径不与内置路由冲突
+func parseFileRoutes(specs []string) ([]fileRoute, error) {
+	var routes []fileRoute
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		route, file, ok := strings.Cut(spec, "=")
+		route, file = strings.TrimSpace(route), strings.TrimSpace(file)
+		if !ok || !strings.HasPrefix(route, "/") || strings.HasSuffix(route, "/") || file == "" {
+			return nil, fmt.Errorf("无效的 -file 参数 %q，格式应为 /路径=文件", spec)
+		}
+		if r, ok := reservedPath(route); ok {
+			return nil, fmt.Errorf("-file 路径 %s 与内置路由 %s 冲突", route, r)
+		}
+		if seen[route] {
+			return nil, fmt.Errorf("-file 路径 %s 重复", route)
+		}
+		seen[route] = true
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("-file 指定的文件 %s 不可用: %w", file, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("-file 指定的 %s 不是普通文件", file)
+		}
+		routes = append(routes, fileRoute{route: route, file: file})
+	}
+	return routes, nil
+}
+
+// serveFileRoute 返回固定输出file的处理函数
+func serveFileRoute(file string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, file)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseMounts(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr string
+	}{
+		{"/docs=" + dir, "/docs", ""},
+		{"/docs/=" + dir, "/docs", ""},
+		{"/=" + dir, "/", ""},
+		{"/api=" + dir, "", "内置路由 /api"},
+		{"/api/v2=" + dir, "", "内置路由 /api"},
+		{"/metrics=" + dir, "", "内置路由 /metrics"},
+		{"/livereload=" + dir, "", "内置路由 /livereload"},
+		{"/checksums.json=" + dir, "", "内置路由 /checksums.json"},
+		{"/apis=" + dir, "/apis", ""},
+		{"docs=" + dir, "", "格式应为"},
+		{"/missing=" + dir + "/nope", "", "不可用"},
+	}
+	for _, tt := range tests {
+		mounts, err := parseMounts([]string{tt.spec})
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseMounts(%q) error = %v, want containing %q", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || len(mounts) != 1 || mounts[0].prefix != tt.want {
+			t.Errorf("parseMounts(%q) = %v, %v, want prefix %q", tt.spec, mounts, err, tt.want)
+		}
+	}
+}
+
+func TestParseFileRoutes(t *testing.T) {
+	file := t.TempDir() + "/icon.png"
+	if err := os.WriteFile(file, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		spec    string
+		wantErr string
+	}{
+		{"/favicon.ico=" + file, ""},
+		{"/metrics=" + file, "内置路由 /metrics"},
+		{"/api/get=" + file, "内置路由 /api"},
+		{"/livereload=" + file, "内置路由 /livereload"},
+		{"/checksums.json=" + file, "内置路由 /checksums.json"},
+		{"/dir/=" + file, "格式应为"},
+		{"/x=" + file + ".missing", "不可用"},
+	}
+	for _, tt := range tests {
+		routes, err := parseFileRoutes([]string{tt.spec})
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseFileRoutes(%q) error = %v, want containing %q", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || len(routes) != 1 {
+			t.Errorf("parseFileRoutes(%q) = %v, %v", tt.spec, routes, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// 编码到预压缩文件后缀的映射；尝试的先后顺序由acceptedSidecarEncodings按客户端偏好决定
+var sidecarExts = map[string]string{
+	"br":   ".br",
+	"gzip": ".gz",
+}
+
+// precompressedHandler 客户端支持时直接返回构建时生成的.br/.gz文件(类似nginx的gzip_static)，
+// Content-Type保持原始文件的类型；找不到预压缩文件时交给next处理原始文件
+func precompressedHandler(fsys http.FileSystem, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if r.Method != http.MethodGet && r.Method != http.MethodHead || strings.HasSuffix(r.URL.Path, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		addVary(w.Header(), "Accept-Encoding")
+
+		for _, encoding := range acceptedSidecarEncodings(r) {
+			f, err := fsys.Open(name + sidecarExts[encoding])
+			if err != nil {
+				continue
+			}
+			info, err := f.Stat()
+			if err != nil || !info.Mode().IsRegular() {
+				f.Close()
+				continue
+			}
+			contentType, err := originalContentType(fsys, name)
+			if err != nil {
+				// 原始文件不存在时不单独提供压缩版本
+				f.Close()
+				break
+			}
+
+			h := w.Header()
+			h.Set("Content-Encoding", encoding)
+			if h.Get("Content-Type") == "" {
+				h.Set("Content-Type", contentType)
+			}
+			// 压缩版本是不同的表示，ETag必须与原始文件区分开
+			h.Set("ETag", fmt.Sprintf(`"%x-%x-%s"`, info.Size(), info.ModTime().UnixNano(), encoding))
+			// Digest是原始文件的校验和，与压缩版本的内容不符
+			h.Del("Digest")
+			http.ServeContent(w, r, name, info.ModTime(), f)
+			f.Close()
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isSidecarETag 判断ETag是否由precompressedHandler为预压缩文件生成(以"-<编码>"结尾)，
+// 其余ETag只含十六进制数字，不会误判
+func isSidecarETag(etag string) bool {
+	for encoding := range sidecarExts {
+		if strings.HasSuffix(etag, "-"+encoding+`"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptedSidecarEncodings 按客户端的偏好返回可用的预压缩编码
+func acceptedSidecarEncodings(r *http.Request) []string {
+	br, gz := encodingQuality(r, "br"), encodingQuality(r, "gzip")
+	switch {
+	case br > 0 && br >= gz:
+		if gz > 0 {
+			return []string{"br", "gzip"}
+		}
+		return []string{"br"}
+	case gz > 0:
+		if br > 0 {
+			return []string{"gzip", "br"}
+		}
+		return []string{"gzip"}
+	}
+	return nil
+}
+
+// originalContentType 按原始文件的扩展名确定类型，无法确定时嗅探原始文件内容
+func originalContentType(fsys http.FileSystem, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s 不是普通文件", name)
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType, nil
+	}
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(f, buf)
+	return http.DetectContentType(buf[:n]), nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// newAPIProxy 创建把/api/请求转发到target的反向代理，保留原始路径和查询参数，
+// 并设置X-Forwarded-For(由ReverseProxy自动追加)与X-Forwarded-Proto
+func newAPIProxy(target string) (http.Handler, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("解析代理地址 %s 失败: %w", target, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("代理地址 %s 必须是 http(s)://host[:port] 形式", target)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		proto := "http"
+		if req.TLS != nil {
+			proto = "https"
+		}
+		director(req)
+		req.Header.Set("X-Forwarded-Proto", proto)
+		req.Header.Set("X-Forwarded-Host", req.Host)
+		req.Host = u.Host
+		// 把请求ID继续传给后端，便于串联整条调用链
+		if id := requestIDFromContext(req.Context()); id != "" {
+			req.Header.Set(requestIDHeader, id)
+		}
+	}
+	// 每次写入都立即刷新，流式响应(如SSE、分块下载)能及时到达客户端
+	proxy.FlushInterval = -1
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("代理请求 %s 失败: %v", r.URL.Path, err)
+		writeJSONError(w, http.StatusBadGateway, "后端服务不可用")
+	}
+	return proxy, nil
+}
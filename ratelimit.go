@@ -0,0 +1,149 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 超过该时长没有请求的令牌桶会被清理
+const bucketIdleTimeout = 3 * time.Minute
+
+// tokenBucket 单个客户端的令牌桶
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter 按客户端IP限流的令牌桶集合，可并发使用
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+// newRateLimiter 创建限流器，每秒补充rate个令牌，桶容量为burst，并在后台定期清理空闲的桶
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	rl := &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+	go rl.cleanup()
+	return rl
+}
+
+// setLimits 修改限流参数，已有的令牌桶保留，rate为0时不再限流
+func (rl *rateLimiter) setLimits(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+	rl.mu.Lock()
+	rl.rate = rate
+	rl.burst = float64(burst)
+	rl.mu.Unlock()
+}
+
+// allow 尝试为key消耗一个令牌，失败时返回需要等待的时长
+func (rl *rateLimiter) allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: rl.burst}
+		rl.buckets[key] = b
+	} else {
+		b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.lastSeen).Seconds()*rl.rate)
+	}
+	b.lastSeen = now
+
+	if rl.rate <= 0 {
+		return true, 0
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	return false, wait
+}
+
+func (rl *rateLimiter) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		rl.mu.Lock()
+		for key, b := range rl.buckets {
+			if time.Since(b.lastSeen) > bucketIdleTimeout {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// rateLimitMiddleware 超出限流的客户端返回429，并通过Retry-After告知需要等待的秒数。
+// 健康检查不计入限流，避免负载均衡的探测被429误判为实例不健康
+func rateLimitMiddleware(rl *rateLimiter, trust proxyTrust, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ok, wait := rl.allow(clientIP(r, trust))
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// proxyTrust 描述部署在反向代理之后时如何信任X-Forwarded-For
+type proxyTrust struct {
+	enabled bool         // -trust-proxy
+	proxies []*net.IPNet // -trusted-proxies，为空表示只信任直接相连的一跳代理
+}
+
+// clientIP 返回客户端IP。启用trust时从右向左查看X-Forwarded-For：
+// 常见代理(如nginx的$proxy_add_x_forwarded_for)是在末尾追加地址的，左侧的内容可被客户端伪造，
+// 因此跳过属于可信代理网段的地址后，取第一个不可信的地址。
+// 配置了可信代理网段时，直接相连的地址不在其中则完全忽略X-Forwarded-For
+func clientIP(r *http.Request, trust proxyTrust) string {
+	peer := remoteHost(r)
+	if !trust.enabled {
+		return peer
+	}
+	if len(trust.proxies) > 0 {
+		if ip := net.ParseIP(peer); ip == nil || !containsIP(trust.proxies, ip) {
+			return peer
+		}
+	}
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// 无法解析的条目之后的内容都不可信，退回直接相连的地址
+			return peer
+		}
+		if i > 0 && containsIP(trust.proxies, ip) {
+			continue
+		}
+		return ip.String()
+	}
+	return peer
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func mustCIDRs(t *testing.T, s string) []*net.IPNet {
+	t.Helper()
+	nets, err := parseCIDRs(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nets
+}
+
+func TestClientIP(t *testing.T) {
+	proxies := mustCIDRs(t, "10.0.0.0/8")
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		trust  proxyTrust
+		want   string
+	}{
+		{"不信任代理时忽略XFF", "192.0.2.1:1234", []string{"203.0.113.9"}, proxyTrust{}, "192.0.2.1"},
+		{"没有XFF", "192.0.2.1:1234", nil, proxyTrust{enabled: true}, "192.0.2.1"},
+		{"单层代理取最右侧", "10.0.0.1:1234", []string{"203.0.113.9"}, proxyTrust{enabled: true}, "203.0.113.9"},
+		{"伪造的左侧条目被忽略", "10.0.0.1:1234", []string{"1.2.3.4, 203.0.113.9"}, proxyTrust{enabled: true}, "203.0.113.9"},
+		{"多个XFF头按顺序拼接", "10.0.0.1:1234", []string{"1.2.3.4", "203.0.113.9"}, proxyTrust{enabled: true}, "203.0.113.9"},
+		{"跳过可信代理", "10.0.0.1:1234", []string{"1.2.3.4, 203.0.113.9, 10.1.1.1"}, proxyTrust{enabled: true, proxies: proxies}, "203.0.113.9"},
+		{"全部是可信代理时取最左侧", "10.0.0.1:1234", []string{"10.2.2.2, 10.1.1.1"}, proxyTrust{enabled: true, proxies: proxies}, "10.2.2.2"},
+		{"直连地址不可信时忽略XFF", "192.0.2.1:1234", []string{"10.9.9.9"}, proxyTrust{enabled: true, proxies: proxies}, "192.0.2.1"},
+		{"无法解析的条目", "10.0.0.1:1234", []string{"1.2.3.4, bogus"}, proxyTrust{enabled: true}, "10.0.0.1"},
+		{"IPv6", "[2001:db8::1]:1234", []string{"2001:db8::2"}, proxyTrust{enabled: true}, "2001:db8::2"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		for _, v := range tt.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := clientIP(r, tt.trust); got != tt.want {
+			t.Errorf("%s: clientIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 轮换伪造的X-Forwarded-For不能绕过限流
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	h := rateLimitMiddleware(newRateLimiter(1, 1), proxyTrust{enabled: true}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	codes := make([]int, 0, 2)
+	for _, fake := range []string{"1.1.1.1", "2.2.2.2"} {
+		r := httptest.NewRequest(http.MethodGet, "/api/get", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", fake+", 203.0.113.9")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("status codes = %v, want [200 429]", codes)
+	}
+}
+
+// 同一客户端超出限流后健康检查仍应正常返回
+func TestRateLimitExemptsHealth(t *testing.T) {
+	h := rateLimitMiddleware(newRateLimiter(1, 1), proxyTrust{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/get", http.StatusOK},
+		{"/api/get", http.StatusTooManyRequests},
+		{"/api/health", http.StatusOK},
+		{"/api/health", http.StatusOK},
+		{"/api/echo", http.StatusTooManyRequests},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("request %d %s: status = %d, want %d", i, tt.path, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// 收到SIGHUP时可以热更新的配置项，其余配置项的修改需要重启才能生效
+var reloadableFlags = map[string]bool{"dir": true, "cors-origin": true, "rate": true, "burst": true}
+
+// staticSite 是根目录静态资源的一份快照，目录切换时整体替换
+type staticSite struct {
+	dir     string
+	fsys    http.FileSystem
+	handler http.Handler
+	sums    *checksumCache // 为nil表示未启用-checksums
+}
+
+// liveConfig 保存可在运行中替换的配置。每个请求只读取一次，
+// 进行中的请求继续使用自己拿到的快照
+type liveConfig struct {
+	site        atomic.Value // *staticSite
+	corsOrigins atomic.Value // []string
+	limiter     *rateLimiter // 为nil表示未启用限流(未指定-config且rate为0)
+
+	// 以下字段只在启动和处理SIGHUP时使用
+	configFile string
+	fs         *flag.FlagSet
+	sources    map[string]string
+	// newSite 为新目录构建静态资源快照，为nil表示目录不可热更新(-embed或-mount覆盖了根路径)
+	newSite func(dir string) (*staticSite, error)
+	// reloader 启用-watch时不为nil，dir切换后改为监听新目录
+	reloader *liveReloader
+	current  map[string]string
+}
+
+func newLiveConfig(configFile string, fs *flag.FlagSet, sources map[string]string) *liveConfig {
+	lc := &liveConfig{configFile: configFile, fs: fs, sources: sources, current: make(map[string]string)}
+	for name := range reloadableFlags {
+		lc.current[name] = fs.Lookup(name).Value.String()
+	}
+	return lc
+}
+
+func (lc *liveConfig) currentSite() *staticSite {
+	return lc.site.Load().(*staticSite)
+}
+
+func (lc *liveConfig) origins() []string {
+	return lc.corsOrigins.Load().([]string)
+}
+
+// ServeHTTP 使用当前的静态资源快照处理请求
+func (lc *liveConfig) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lc.currentSite().handler.ServeHTTP(w, r)
+}
+
+// serveChecksums 处理/checksums.json，清单跟随当前目录
+func (lc *liveConfig) serveChecksums(w http.ResponseWriter, r *http.Request) {
+	lc.currentSite().sums.ServeHTTP(w, r)
+}
+
+// liveSiteFS 始终从当前的静态资源目录打开文件
+type liveSiteFS struct{ lc *liveConfig }
+
+func (fs liveSiteFS) Open(name string) (http.File, error) {
+	return fs.lc.currentSite().fsys.Open(name)
+}
+
+// reload 重新读取配置文件并替换可热更新的配置。
+// 任何一项校验失败时整体放弃，继续使用原有配置
+func (lc *liveConfig) reload() error {
+	if lc.configFile == "" {
+		return errors.New("未指定 -config，没有可重新加载的配置文件")
+	}
+	cfg, err := loadConfig(lc.configFile)
+	if err != nil {
+		return err
+	}
+	values := cfg.values()
+
+	// 命令行参数和环境变量的优先级高于配置文件，不会被重新加载覆盖
+	next := make(map[string]string)
+	for name := range reloadableFlags {
+		next[name] = lc.current[name]
+		if source := lc.sources[name]; source == sourceFlag || source == sourceEnv {
+			continue
+		}
+		if v, ok := values[name]; ok {
+			next[name] = v
+		} else {
+			next[name] = lc.fs.Lookup(name).DefValue
+		}
+	}
+	for name, v := range values {
+		if source := lc.sources[name]; reloadableFlags[name] || source == sourceFlag || source == sourceEnv {
+			continue
+		}
+		if v != lc.fs.Lookup(name).Value.String() {
+			log.Printf("配置项 %s 已修改，需要重启才能生效", name)
+		}
+	}
+
+	rate, err := strconv.ParseFloat(next["rate"], 64)
+	if err != nil || rate < 0 {
+		return fmt.Errorf("rate 的值 %q 无效", next["rate"])
+	}
+	burst, err := strconv.Atoi(next["burst"])
+	if err != nil {
+		return fmt.Errorf("burst 的值 %q 无效", next["burst"])
+	}
+	// 目录不可热更新时只忽略dir，其余配置照常生效
+	if lc.newSite == nil && next["dir"] != lc.current["dir"] {
+		if _, ok := values["dir"]; ok {
+			log.Printf("使用 -embed 或 -mount 覆盖根路径时不支持热更新 dir，已忽略")
+		}
+		next["dir"] = lc.current["dir"]
+	}
+	var site *staticSite
+	if next["dir"] != lc.current["dir"] {
+		if site, err = lc.newSite(next["dir"]); err != nil {
+			return err
+		}
+	}
+
+	// 全部校验通过后再替换
+	var changes []string
+	for _, name := range []string{"dir", "cors-origin", "rate", "burst"} {
+		if next[name] != lc.current[name] {
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", name, lc.current[name], next[name]))
+		}
+	}
+	if site != nil {
+		lc.site.Store(site)
+		if lc.reloader != nil {
+			if err := lc.reloader.replaceDir(lc.current["dir"], next["dir"]); err != nil {
+				log.Printf("livereload: 切换监听目录失败: %v", err)
+			}
+		}
+	}
+	lc.corsOrigins.Store(splitList(next["cors-origin"]))
+	if lc.limiter != nil {
+		lc.limiter.setLimits(rate, burst)
+	}
+	lc.current = next
+
+	if len(changes) == 0 {
+		log.Printf("已重新加载配置文件 %s，可热更新的配置无变化", lc.configFile)
+	} else {
+		log.Printf("已重新加载配置文件 %s: %s", lc.configFile, strings.Join(changes, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// reloadTest 模拟启动后的liveConfig，flag和env指定的配置项按对应来源记录
+type reloadTest struct {
+	t      *testing.T
+	lc     *liveConfig
+	config string
+	old    *staticSite
+}
+
+func newReloadTest(t *testing.T, args []string, env map[string]string, dirReloadable bool) *reloadTest {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("addr", ":8088", "")
+	fs.String("dir", t.TempDir(), "")
+	fs.String("cors-origin", "", "")
+	fs.Float64("rate", 0, "")
+	fs.Int("burst", 10, "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	sources := explicitFlags(fs)
+	for name, v := range env {
+		if err := fs.Set(name, v); err != nil {
+			t.Fatal(err)
+		}
+		sources[name] = sourceEnv
+	}
+
+	rt := &reloadTest{t: t, config: filepath.Join(t.TempDir(), "config.json")}
+	rt.lc = newLiveConfig(rt.config, fs, sources)
+	rt.old = &staticSite{dir: fs.Lookup("dir").Value.String()}
+	rt.lc.site.Store(rt.old)
+	rt.lc.corsOrigins.Store(splitList(fs.Lookup("cors-origin").Value.String()))
+	rt.lc.limiter = newRateLimiter(0, 10)
+	if dirReloadable {
+		rt.lc.newSite = func(dir string) (*staticSite, error) {
+			if err := checkDir(dir); err != nil {
+				return nil, err
+			}
+			return &staticSite{dir: dir}, nil
+		}
+	}
+	return rt
+}
+
+// reload 写入配置文件内容后调用reload
+func (rt *reloadTest) reload(config string) error {
+	rt.t.Helper()
+	if err := os.WriteFile(rt.config, []byte(config), 0o644); err != nil {
+		rt.t.Fatal(err)
+	}
+	return rt.lc.reload()
+}
+
+// check 检查当前生效的目录、CORS来源和限流参数
+func (rt *reloadTest) check(step, dir string, origins []string, rate, burst float64) {
+	rt.t.Helper()
+	if got := rt.lc.currentSite().dir; got != dir {
+		rt.t.Errorf("%s: dir = %q, want %q", step, got, dir)
+	}
+	if got := rt.lc.origins(); !slices.Equal(got, origins) {
+		rt.t.Errorf("%s: origins = %q, want %q", step, got, origins)
+	}
+	rt.lc.limiter.mu.Lock()
+	gotRate, gotBurst := rt.lc.limiter.rate, rt.lc.limiter.burst
+	rt.lc.limiter.mu.Unlock()
+	if gotRate != rate || gotBurst != burst {
+		rt.t.Errorf("%s: rate, burst = %v, %v, want %v, %v", step, gotRate, gotBurst, rate, burst)
+	}
+}
+
+// 命令行和环境变量指定的配置项不被配置文件覆盖，配置文件中删除的项恢复默认值
+func TestReloadPrecedence(t *testing.T) {
+	rt := newReloadTest(t, []string{"-cors-origin=https://flag.example"}, map[string]string{"rate": "5"}, true)
+	rt.lc.limiter.setLimits(5, 10)
+	dir := rt.old.dir
+
+	if err := rt.reload(`{"cors-origin": "https://file.example", "rate": 9, "burst": 3, "addr": ":9000"}`); err != nil {
+		t.Fatal(err)
+	}
+	rt.check("配置文件设置burst", dir, []string{"https://flag.example"}, 5, 3)
+
+	if err := rt.reload(`{}`); err != nil {
+		t.Fatal(err)
+	}
+	rt.check("删除burst", dir, []string{"https://flag.example"}, 5, 10)
+}
+
+// 删除配置文件中的cors-origin和rate后恢复默认值(不允许跨域、不限流)，dir保持不变
+func TestReloadRemovedKeyRevertsToDefault(t *testing.T) {
+	rt := newReloadTest(t, nil, nil, true)
+	newDir := t.TempDir()
+
+	if err := rt.reload(`{"dir": "` + filepath.ToSlash(newDir) + `", "cors-origin": "https://a.example,https://b.example", "rate": 2}`); err != nil {
+		t.Fatal(err)
+	}
+	rt.check("设置", newDir, []string{"https://a.example", "https://b.example"}, 2, 10)
+
+	if err := rt.reload(`{"dir": "` + filepath.ToSlash(newDir) + `"}`); err != nil {
+		t.Fatal(err)
+	}
+	rt.check("删除", newDir, nil, 0, 10)
+}
+
+// dir无效时整体放弃本次重新加载，继续使用原有的目录和其他配置
+func TestReloadRejectsBadDir(t *testing.T) {
+	rt := newReloadTest(t, nil, nil, true)
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	if err := rt.reload(`{"dir": "` + filepath.ToSlash(missing) + `", "cors-origin": "https://new.example", "rate": 3}`); err == nil {
+		t.Fatal("目录不存在时reload应返回错误")
+	}
+	if rt.lc.currentSite() != rt.old {
+		t.Error("目录无效时不应替换静态资源快照")
+	}
+	rt.check("无效目录", rt.old.dir, nil, 0, 10)
+
+	for _, config := range []string{`{"rate": -1}`, `{"burst": "x"}`, `{"unknown": 1}`} {
+		if err := rt.reload(config); err == nil {
+			t.Errorf("%s: reload应返回错误", config)
+		}
+	}
+	rt.check("无效配置", rt.old.dir, nil, 0, 10)
+}
+
+// -embed或-mount覆盖根路径时dir不可热更新，只忽略dir，其余配置照常生效
+func TestReloadIgnoresDirWhenNotReloadable(t *testing.T) {
+	rt := newReloadTest(t, nil, nil, false)
+
+	if err := rt.reload(`{"dir": "` + filepath.ToSlash(t.TempDir()) + `", "cors-origin": "https://new.example", "burst": 4}`); err != nil {
+		t.Fatal(err)
+	}
+	if rt.lc.currentSite() != rt.old {
+		t.Error("不可热更新时不应替换静态资源快照")
+	}
+	if rt.lc.current["dir"] != rt.old.dir {
+		t.Errorf("current dir = %q, want %q", rt.lc.current["dir"], rt.old.dir)
+	}
+	rt.check("忽略dir", rt.old.dir, []string{"https://new.example"}, 0, 4)
+}